@@ -8,10 +8,91 @@ const (
 	MANAGERS          = "fut22Managers"
 	SEASONS           = "fut22Seasons"
 	RESULTS           = "fut22Results"
+	RESULTAUDITS      = "fut22ResultAudits"
+	LEDGER            = "fut22Ledger"
+	LEAGUES           = "fut22Leagues"
+	PACKS             = "fut22Packs"
+	SETTINGS          = "fut22Settings"
+	SEEDS             = "fut22Seeds"
+	DRAWS             = "fut22Draws"
+	POOLS             = "fut22Pools"
+	PACKOPENINGS      = "fut22PackOpenings"
+	DRAFTS            = "fut22Drafts"
+	PLAYERCLAIMS      = "fut22PlayerClaims"
+	LISTINGS          = "fut22Listings"
+	TRADES            = "fut22Trades"
 	TOPPLAYERS        = "topPlayers"
 	ALLPLAYERS        = "-----[]-[]-[]"
 	ALLPLAYERLIMIT    = 3000
 	RANDOMPLAYERLIMIT = 68
+	POOLINDEXPREFIX   = "pool:"
+	POOLINDEXTTL      = 60 * 60
+	DRAWSESSIONPREFIX = "draw:"
+)
+
+// /player/random draws from a shared session per filter, kept for
+// RANDOMSESSIONMINUTES after it is filled
+const (
+	RANDOMSESSIONPREFIX  = "random:"
+	RANDOMSESSIONMINUTES = 45
+)
+
+// a club's strength is the average overall of its best CLUBSQUADSIZE players,
+// clubs assigned in one round are at most CLUBSPREAD apart unless asked
+// otherwise
+const (
+	CLUBSQUADSIZE = 11
+	CLUBSPREAD    = 2.0
+)
+
+// drafts
+const (
+	DRAFTACTIVE    = "active"
+	DRAFTCOMPLETE  = "complete"
+	DRAFTRANDOM    = "random"
+	DRAFTSTANDINGS = "standings"
+	DRAFTSNAKE     = "snake"
+	DRAFTLINEAR    = "linear"
+)
+
+// a pick takes at most DRAFTPICKSECONDS unless the draft says otherwise, the
+// clock is checked every DRAFTCLOCKSECONDS
+const (
+	DRAFTPICKSECONDS  = 90
+	DRAFTCLOCKSECONDS = 5
+)
+
+var DEFAULT_DRAFT_POSITIONS = []string{"GK", "RB", "CB", "CB", "LB", "CM", "CM", "CM", "RW", "ST", "LW"}
+
+// listing states
+const (
+	LISTINGOPEN      = "open"
+	LISTINGSOLD      = "sold"
+	LISTINGEXPIRED   = "expired"
+	LISTINGCANCELLED = "cancelled"
+)
+
+// trade states
+const (
+	TRADEPENDING   = "pending"
+	TRADEACCEPTED  = "accepted"
+	TRADEREJECTED  = "rejected"
+	TRADECOUNTERED = "countered"
+	TRADECANCELLED = "cancelled"
+)
+
+// auctions run MARKETHOURS unless the seller picks up to MARKETMAXHOURS, the
+// worker settles ended auctions every MARKETCLOCKSECONDS
+const (
+	MARKETHOURS        = 24
+	MARKETMAXHOURS     = 72
+	MARKETCLOCKSECONDS = 15
+)
+
+// draw sessions expire after DRAWSESSIONMINUTES unless asked otherwise
+const (
+	DRAWSESSIONMINUTES    = 120
+	DRAWSESSIONMAXMINUTES = 24 * 60
 )
 
 // error messages
@@ -25,8 +106,143 @@ const (
 	GETPLAYERERROR    = "Get player error"
 	GETSEASONERROR    = "Get season error"
 	UPDATEERROR       = "Update error"
+	FIXTUREERROR      = "Fixture error"
+	BRACKETERROR      = "Bracket error"
+	GROUPERROR        = "Group error"
+	RULESERROR        = "Rules error"
+	STATUSERROR       = "Season status error"
+	REGISTRATIONERROR = "Registration error"
+	RESULTERROR       = "Result error"
+	GETRESULTERROR    = "Get result error"
+	AUDITERROR        = "Audit error"
+	LEDGERERROR       = "Ledger error"
+	AUTHERROR         = "Authentication error"
+	FORBIDDENERROR    = "Forbidden"
+	ROLEERROR         = "Role error"
+	LEAGUEERROR       = "League error"
+	PACKERROR         = "Pack error"
+	QUICKSELLERROR    = "Quick sell error"
+	DRAWERROR         = "Draw error"
+	PACKHISTORYERROR  = "Pack history error"
+	DRAWSESSIONERROR  = "Draw session error"
+	CLUBERROR         = "Club assignment error"
+	DRAFTERROR        = "Draft error"
+	MARKETERROR       = "Transfer market error"
+	TRADEERROR        = "Trade error"
+)
+
+// manager roles
+const (
+	ROLEADMIN   = "admin"
+	ROLEOWNER   = "leagueOwner"
+	ROLEMANAGER = "manager"
+)
+
+var ROLES = []string{ROLEADMIN, ROLEOWNER, ROLEMANAGER}
+
+// invite codes leave out characters which are easy to mix up
+const (
+	INVITECODECHARS  = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	INVITECODELENGTH = 8
+)
+
+// firebase
+const (
+	FIREBASEISSUER   = "https://securetoken.google.com/"
+	FIREBASECERTSURL = "https://www.googleapis.com/robot/v1/metadata/x509/securetoken@system.gserviceaccount.com"
 )
 
+// season types
+const (
+	LEAGUE     = "league"
+	CUP        = "cup"
+	TOURNAMENT = "tournament"
+)
+
+// two managers of a season without fixtures meet at most MAXMEETINGS times
+const MAXMEETINGS = 10
+
+// audit actions
+const (
+	AUDITUPDATE = "update"
+	AUDITDELETE = "delete"
+)
+
+// draw kinds
+const (
+	DRAWPACK   = "pack"
+	DRAWPLAYER = "player"
+)
+
+// ledger sources
+const (
+	LEDGERADMIN     = "admin"
+	LEDGERPACK      = "pack"
+	LEDGERREWARD    = "reward"
+	LEDGEROPENING   = "opening"
+	LEDGERCORRECT   = "correction"
+	LEDGERQUICKSELL = "quicksell"
+	LEDGERESCROW    = "escrow"
+	LEDGERREFUND    = "refund"
+	LEDGERTRANSFER  = "transfer"
+	LEDGERTRADE     = "trade"
+)
+
+const (
+	LEDGERPAGESIZE    = 20
+	LEDGERMAXPAGESIZE = 100
+)
+
+// pack statistics cover the last PACKSTATSDAYS days and rank the best pulls
+const (
+	PACKSTATSDAYS  = 7
+	PACKSTATSPULLS = 5
+)
+
+// season states
+const (
+	STATUSDRAFT        = "draft"
+	STATUSREGISTRATION = "registration"
+	STATUSACTIVE       = "active"
+	STATUSFINISHED     = "finished"
+	STATUSARCHIVED     = "archived"
+)
+
+var SEASON_TRANSITIONS = map[string][]string{
+	STATUSDRAFT:        {STATUSREGISTRATION, STATUSACTIVE},
+	STATUSREGISTRATION: {STATUSDRAFT, STATUSACTIVE},
+	STATUSACTIVE:       {STATUSFINISHED},
+	STATUSFINISHED:     {STATUSACTIVE, STATUSARCHIVED},
+	STATUSARCHIVED:     {},
+}
+
+// standing tiebreakers
+const (
+	GOALDIFFERENCE   = "goalDifference"
+	GOALSFOR         = "goalsFor"
+	HEADTOHEADPOINTS = "headToHeadPoints"
+	HEADTOHEADGD     = "headToHeadGoalDifference"
+	WINS             = "wins"
+	FAIRPLAY         = "fairPlay"
+	MANAGERNAME      = "managerName"
+)
+
+var TIEBREAKERS = []string{GOALDIFFERENCE, GOALSFOR, HEADTOHEADPOINTS, HEADTOHEADGD, WINS, FAIRPLAY}
+
+var DEFAULT_TIEBREAKERS = []string{GOALDIFFERENCE, GOALSFOR}
+
+// player rarities, pack odds rows and quick sell bands can be limited to one.
+// Players in their club's starting eleven are rare, substitutes and reserves
+// common.
+const (
+	RARITYCOMMON = "common"
+	RARITYRARE   = "rare"
+	SUBPOSITION  = "SUB"
+	RESPOSITION  = "RES"
+)
+
+var RARITIES = []string{RARITYCOMMON, RARITYRARE}
+
 var OVERALLOPTION = bson.D{{Key: "overall", Value: -1}}
 
 const (
@@ -38,15 +254,6 @@ const (
 	PRIMEGOLD
 )
 
-var PACK_PRICES = map[int]int{
-	SILVER:        500,
-	PREMIUMSILVER: 750,
-	GOLD:          2000,
-	PREMIUMGOLD:   5000,
-	ULTIMATEGOLD:  10000,
-	PRIMEGOLD:     15000,
-}
-
 var WILL_HIDE_VIA_LEAGUE = []string{
 	"Czech Republic Gambrinus Liga",
 	"Hungarian Nemzeti Bajnokság I",
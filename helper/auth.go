@@ -0,0 +1,280 @@
+package helper
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auth-start
+type FirebaseToken struct {
+	Issuer   string `json:"iss"`
+	Audience string `json:"aud"`
+	Subject  string `json:"sub"`
+	Email    string `json:"email"`
+	IssuedAt int64  `json:"iat"`
+	Expires  int64  `json:"exp"`
+	AuthTime int64  `json:"auth_time"`
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// keySet caches the public keys Firebase signs ID tokens with. Keys come from
+// FIREBASE_CERTS_FILE when it is set, otherwise from FIREBASE_CERTS_URL and
+// are kept for as long as the response's Cache-Control max-age allows.
+// Refreshes run one at a time, callers waiting on one use its result.
+type keySet struct {
+	mu         sync.RWMutex
+	keys       map[string]*rsa.PublicKey
+	expires    time.Time
+	refreshing sync.Mutex
+	refreshed  time.Time
+}
+
+var firebaseKeys = &keySet{}
+
+// certsClient fetches the signing keys, a hanging endpoint can't hold up the
+// requests waiting for a key.
+var certsClient = &http.Client{Timeout: 10 * time.Second}
+
+// unknownKeyInterval limits how often tokens with an unknown kid trigger a
+// refresh of a set which hasn't expired yet.
+const unknownKeyInterval = time.Minute
+
+var maxAgePattern = regexp.MustCompile(`max-age=(\d+)`)
+
+// tokenLeeway tolerates small clock differences between us and Firebase.
+const tokenLeeway = 5 * time.Minute
+
+type contextKey string
+
+const (
+	callerKey contextKey = "caller"
+	tokenKey  contextKey = "token"
+)
+
+// VerifyIDToken checks the signature and claims of a Firebase ID token and
+// returns its claims.
+func VerifyIDToken(idToken string) (FirebaseToken, error) {
+	token := FirebaseToken{}
+	projectID, err := GetEnv("FIREBASE_PROJECT_ID")
+	if err != nil {
+		return token, err
+	}
+
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return token, fmt.Errorf("token should have three parts")
+	}
+
+	header := tokenHeader{}
+	err = decodeSegment(parts[0], &header)
+	if err != nil {
+		return token, err
+	}
+	if header.Algorithm != "RS256" {
+		return token, fmt.Errorf("unexpected signing algorithm %s", header.Algorithm)
+	}
+
+	key, err := firebaseKeys.key(header.KeyID)
+	if err != nil {
+		return token, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return token, err
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature)
+	if err != nil {
+		return token, fmt.Errorf("invalid token signature")
+	}
+
+	err = decodeSegment(parts[1], &token)
+	if err != nil {
+		return token, err
+	}
+
+	now := time.Now()
+	switch {
+	case token.Audience != projectID:
+		return token, fmt.Errorf("token audience %s doesn't match the project", token.Audience)
+	case token.Issuer != constant.FIREBASEISSUER+projectID:
+		return token, fmt.Errorf("token issuer %s doesn't match the project", token.Issuer)
+	case token.Subject == "" || len(token.Subject) > 128:
+		return token, fmt.Errorf("token has no valid subject")
+	case now.Add(-tokenLeeway).After(time.Unix(token.Expires, 0)):
+		return token, fmt.Errorf("token expired")
+	case now.Add(tokenLeeway).Before(time.Unix(token.IssuedAt, 0)):
+		return token, fmt.Errorf("token issued in the future")
+	case now.Add(tokenLeeway).Before(time.Unix(token.AuthTime, 0)):
+		return token, fmt.Errorf("token authenticated in the future")
+	}
+
+	return token, nil
+}
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// key returns the public key for kid, refreshing the set when it expired or
+// doesn't know the key yet. An unknown kid refreshes a fresh set at most once
+// per unknownKeyInterval.
+func (ks *keySet) key(kid string) (*rsa.PublicKey, error) {
+	key, found, fresh := ks.lookup(kid)
+	if found && fresh {
+		return key, nil
+	}
+
+	ks.refreshing.Lock()
+	// another caller may have refreshed while this one waited
+	key, found, fresh = ks.lookup(kid)
+	if !fresh || (!found && time.Since(ks.refreshed) >= unknownKeyInterval) {
+		err := ks.refresh()
+		if err != nil {
+			ks.refreshing.Unlock()
+			return nil, err
+		}
+		key, found, _ = ks.lookup(kid)
+	}
+	ks.refreshing.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("unknown signing key %s", kid)
+	}
+	return key, nil
+}
+func (ks *keySet) lookup(kid string) (*rsa.PublicKey, bool, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, found := ks.keys[kid]
+	return key, found, time.Now().Before(ks.expires)
+}
+
+// refresh loads the keys again, callers hold ks.refreshing.
+func (ks *keySet) refresh() error {
+	ks.refreshed = time.Now()
+	var data []byte
+	expires := time.Now().Add(time.Hour)
+
+	file, err := GetEnv("FIREBASE_CERTS_FILE")
+	if err == nil {
+		data, err = ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+	} else {
+		url, err := GetEnv("FIREBASE_CERTS_URL")
+		if err != nil {
+			url = constant.FIREBASECERTSURL
+		}
+		response, err := certsClient.Get(url)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("fetching signing keys failed with %d", response.StatusCode)
+		}
+		data, err = ioutil.ReadAll(response.Body)
+		if err != nil {
+			return err
+		}
+		match := maxAgePattern.FindStringSubmatch(response.Header.Get("Cache-Control"))
+		if match != nil {
+			seconds, _ := strconv.Atoi(match[1])
+			expires = time.Now().Add(time.Duration(seconds) * time.Second)
+		}
+	}
+
+	keys, err := ParseCertificates(data)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.expires = expires
+	ks.mu.Unlock()
+	return nil
+}
+
+// ParseCertificates reads the kid to PEM certificate map Firebase publishes.
+func ParseCertificates(data []byte) (map[string]*rsa.PublicKey, error) {
+	certificates := map[string]string{}
+	err := json.Unmarshal(data, &certificates)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for kid, certificate := range certificates {
+		block, _ := pem.Decode([]byte(certificate))
+		if block == nil {
+			return nil, fmt.Errorf("signing key %s is not PEM encoded", kid)
+		}
+		parsed, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := parsed.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("signing key %s is not an RSA key", kid)
+		}
+		keys[kid] = key
+	}
+
+	return keys, nil
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer" header.
+func BearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", fmt.Errorf("missing bearer token")
+	}
+	return strings.TrimPrefix(header, "Bearer "), nil
+}
+
+func WithToken(ctx context.Context, token FirebaseToken) context.Context {
+	return context.WithValue(ctx, tokenKey, token)
+}
+func WithCaller(ctx context.Context, manager structs.Manager) context.Context {
+	return context.WithValue(ctx, callerKey, manager)
+}
+
+// Token returns the verified token claims of the request.
+func Token(r *http.Request) (FirebaseToken, bool) {
+	token, ok := r.Context().Value(tokenKey).(FirebaseToken)
+	return token, ok
+}
+
+// Caller returns the manager who sent the request.
+func Caller(r *http.Request) (structs.Manager, bool) {
+	manager, ok := r.Context().Value(callerKey).(structs.Manager)
+	return manager, ok
+}
+
+// auth-end
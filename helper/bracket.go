@@ -0,0 +1,335 @@
+package helper
+
+import (
+	"fmt"
+	"manager-sensin/request"
+	"manager-sensin/structs"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// bracket-start
+// GenerateBracket draws a knockout bracket for the season. Seeded draws keep
+// the given order as seeds so the top seeds receive the byes and can only meet
+// in the late rounds, otherwise the managers are shuffled first.
+func GenerateBracket(season *structs.Season, managers []structs.Manager, seeded, twoLegged bool) error {
+	if len(managers) < 2 {
+		return fmt.Errorf("at least two managers needed to draw a bracket")
+	}
+
+	if !seeded {
+		shuffleManagers(managers)
+	}
+
+	size := 2
+	for size < len(managers) {
+		size *= 2
+	}
+
+	matchday := 0
+	for _, fixture := range season.Fixtures {
+		if fixture.Matchday > matchday {
+			matchday = fixture.Matchday
+		}
+	}
+
+	bracket := &structs.Bracket{TwoLegged: twoLegged}
+	for teams := size; teams > 1; teams /= 2 {
+		matchday++
+		round := structs.Round{Name: roundName(teams), Matchday: matchday}
+		for i := 0; i < teams/2; i++ {
+			round.Ties = append(round.Ties, structs.Tie{ID: primitive.NewObjectID()})
+		}
+		bracket.Rounds = append(bracket.Rounds, round)
+	}
+
+	season.Bracket = bracket
+
+	order := seedOrder(size)
+	for i := range bracket.Rounds[0].Ties {
+		tie := &bracket.Rounds[0].Ties[i]
+		for slot, seed := range order[i*2 : i*2+2] {
+			if seed > len(managers) {
+				continue
+			}
+			setTieSlot(tie, slot, managers[seed-1].ID.Hex(), managers[seed-1].Name)
+		}
+	}
+
+	for i := range bracket.Rounds[0].Ties {
+		tie := &bracket.Rounds[0].Ties[i]
+		if tie.Away == "" {
+			tie.Winner = tie.Home
+			advanceWinner(season, 0, i)
+		} else {
+			createTieLegs(season, 0, i)
+		}
+	}
+
+	return nil
+}
+
+// ValidateTieResult makes sure a result which settles a level tie comes with
+// a decisive penalty shoot-out, and that no other result carries one.
+func ValidateTieResult(season *structs.Season, fixtureIndex int, score, penalties []int) error {
+	fixture := season.Fixtures[fixtureIndex]
+	if season.Bracket == nil || fixture.Tie == "" {
+		return nil
+	}
+	found, roundIndex, tieIndex := season.Bracket.TieByID(fixture.Tie)
+	if !found {
+		return fmt.Errorf("tie %s not found in bracket", fixture.Tie)
+	}
+	tie := season.Bracket.Rounds[roundIndex].Ties[tieIndex]
+	if len(score) != 2 {
+		return fmt.Errorf("score should contain home and away goals")
+	}
+
+	homeGoals, awayGoals := 0, 0
+	remaining := 0
+	for _, legID := range tie.Legs {
+		_, legIndex := season.FixtureByID(legID)
+		leg := season.Fixtures[legIndex]
+		legScore := leg.Score
+		if legIndex == fixtureIndex {
+			legScore = score
+		} else if leg.Result == "" {
+			remaining++
+			continue
+		}
+		if leg.Home == tie.Home {
+			homeGoals += legScore[0]
+			awayGoals += legScore[1]
+		} else {
+			homeGoals += legScore[1]
+			awayGoals += legScore[0]
+		}
+	}
+
+	level := remaining == 0 && homeGoals == awayGoals
+	if level && (len(penalties) != 2 || penalties[0] == penalties[1]) {
+		return fmt.Errorf("tie is level on aggregate, a decisive penalty shoot-out is needed")
+	}
+	if !level && len(penalties) > 0 {
+		return fmt.Errorf("penalties can only be recorded when the tie is level on aggregate")
+	}
+
+	return nil
+}
+
+// AdvanceBracket recomputes the aggregate of the tie the played fixture
+// belongs to and moves the winner into the next round once it is decided.
+func AdvanceBracket(season *structs.Season, fixtureIndex int) {
+	fixture := season.Fixtures[fixtureIndex]
+	if season.Bracket == nil || fixture.Tie == "" {
+		return
+	}
+	found, roundIndex, tieIndex := season.Bracket.TieByID(fixture.Tie)
+	if !found {
+		return
+	}
+	tie := &season.Bracket.Rounds[roundIndex].Ties[tieIndex]
+
+	aggregate := []int{0, 0}
+	penalties := []int{}
+	for _, legID := range tie.Legs {
+		_, legIndex := season.FixtureByID(legID)
+		leg := season.Fixtures[legIndex]
+		if leg.Result == "" {
+			return
+		}
+		if leg.Home == tie.Home {
+			aggregate[0] += leg.Score[0]
+			aggregate[1] += leg.Score[1]
+			if len(leg.Penalties) == 2 {
+				penalties = []int{leg.Penalties[0], leg.Penalties[1]}
+			}
+		} else {
+			aggregate[0] += leg.Score[1]
+			aggregate[1] += leg.Score[0]
+			if len(leg.Penalties) == 2 {
+				penalties = []int{leg.Penalties[1], leg.Penalties[0]}
+			}
+		}
+	}
+
+	tie.Aggregate = aggregate
+	tie.Penalties = penalties
+	if aggregate[0] > aggregate[1] || (aggregate[0] == aggregate[1] && len(penalties) == 2 && penalties[0] > penalties[1]) {
+		tie.Winner = tie.Home
+	} else if aggregate[1] > aggregate[0] || (len(penalties) == 2 && penalties[1] > penalties[0]) {
+		tie.Winner = tie.Away
+	} else {
+		return
+	}
+
+	advanceWinner(season, roundIndex, tieIndex)
+}
+
+// RollbackFixture clears what was derived from a played fixture so its result
+// can be edited or removed: the advancement of its tie and, for group games,
+// the knockout draw. It refuses once the next stage has been played.
+func RollbackFixture(season *structs.Season, fixtureIndex int) error {
+	fixture := season.Fixtures[fixtureIndex]
+	if season.Bracket == nil {
+		return nil
+	}
+
+	if fixture.Group != "" {
+		for _, knockout := range season.Fixtures {
+			if knockout.Tie != "" && knockout.Result != "" {
+				return fmt.Errorf("knockout stage already started")
+			}
+		}
+		fixtures := []structs.Fixture{}
+		for _, f := range season.Fixtures {
+			if f.Tie == "" {
+				fixtures = append(fixtures, f)
+			}
+		}
+		season.Fixtures = fixtures
+		season.Bracket = nil
+		return nil
+	}
+
+	found, roundIndex, tieIndex := season.Bracket.TieByID(fixture.Tie)
+	if !found {
+		return nil
+	}
+	tie := &season.Bracket.Rounds[roundIndex].Ties[tieIndex]
+	if tie.Decided() && roundIndex+1 < len(season.Bracket.Rounds) {
+		next := &season.Bracket.Rounds[roundIndex+1].Ties[tieIndex/2]
+		for _, legID := range next.Legs {
+			legFound, legIndex := season.FixtureByID(legID)
+			if legFound && season.Fixtures[legIndex].Result != "" {
+				return fmt.Errorf("%s already played in the %s", tie.Winner, season.Bracket.Rounds[roundIndex+1].Name)
+			}
+		}
+
+		fixtures := []structs.Fixture{}
+		for _, f := range season.Fixtures {
+			isLeg, _ := IsExistInSlice(next.Legs, f.ID.Hex())
+			if !isLeg {
+				fixtures = append(fixtures, f)
+			}
+		}
+		season.Fixtures = fixtures
+		next.Legs = nil
+		setTieSlot(next, tieIndex%2, "", "")
+	}
+
+	tie.Winner = ""
+	tie.Aggregate = nil
+	tie.Penalties = nil
+	return nil
+}
+
+func advanceWinner(season *structs.Season, roundIndex, tieIndex int) {
+	if roundIndex+1 >= len(season.Bracket.Rounds) {
+		return
+	}
+	tie := season.Bracket.Rounds[roundIndex].Ties[tieIndex]
+	name := tie.HomeManager
+	if tie.Winner == tie.Away {
+		name = tie.AwayManager
+	}
+
+	next := &season.Bracket.Rounds[roundIndex+1].Ties[tieIndex/2]
+	setTieSlot(next, tieIndex%2, tie.Winner, name)
+	if next.Home != "" && next.Away != "" {
+		createTieLegs(season, roundIndex+1, tieIndex/2)
+	}
+}
+
+func createTieLegs(season *structs.Season, roundIndex, tieIndex int) {
+	tie := &season.Bracket.Rounds[roundIndex].Ties[tieIndex]
+	legs := 1
+	if season.Bracket.TwoLegged && roundIndex < len(season.Bracket.Rounds)-1 {
+		legs = 2
+	}
+
+	for i := 0; i < legs; i++ {
+		leg := structs.Fixture{
+			ID:          primitive.NewObjectID(),
+			Matchday:    season.Bracket.Rounds[roundIndex].Matchday,
+			Home:        tie.Home,
+			Away:        tie.Away,
+			HomeManager: tie.HomeManager,
+			AwayManager: tie.AwayManager,
+			Tie:         tie.ID.Hex(),
+		}
+		if i == 1 {
+			leg.Home, leg.Away = tie.Away, tie.Home
+			leg.HomeManager, leg.AwayManager = tie.AwayManager, tie.HomeManager
+		}
+		tie.Legs = append(tie.Legs, leg.ID.Hex())
+		season.Fixtures = append(season.Fixtures, leg)
+	}
+}
+
+func setTieSlot(tie *structs.Tie, slot int, manager, name string) {
+	if slot == 0 {
+		tie.Home = manager
+		tie.HomeManager = name
+	} else {
+		tie.Away = manager
+		tie.AwayManager = name
+	}
+}
+
+// seedOrder returns the bracket positions of the seeds, e.g. 1,8,4,5,2,7,3,6
+// for eight slots, so that seed 1 and 2 can only meet in the final.
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := []int{}
+		for _, seed := range order {
+			next = append(next, seed, len(order)*2+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+func roundName(teams int) string {
+	switch teams {
+	case 2:
+		return "Final"
+	case 4:
+		return "Semi-final"
+	case 8:
+		return "Quarter-final"
+	default:
+		return fmt.Sprintf("Round of %d", teams)
+	}
+}
+
+func BracketResponse(season structs.Season) request.BracketResponse {
+	response := request.BracketResponse{
+		Season: season.ID.Hex(),
+		Rounds: []request.BracketRound{},
+	}
+	if season.Bracket == nil {
+		return response
+	}
+
+	response.TwoLegged = season.Bracket.TwoLegged
+	for _, round := range season.Bracket.Rounds {
+		bracketRound := request.BracketRound{Name: round.Name, Ties: []request.BracketTie{}}
+		for _, tie := range round.Ties {
+			bracketTie := request.BracketTie{Tie: tie, Legs: []structs.Fixture{}}
+			for _, legID := range tie.Legs {
+				found, legIndex := season.FixtureByID(legID)
+				if found {
+					bracketTie.Legs = append(bracketTie.Legs, season.Fixtures[legIndex])
+				}
+			}
+			bracketRound.Ties = append(bracketRound.Ties, bracketTie)
+		}
+		response.Rounds = append(response.Rounds, bracketRound)
+	}
+
+	return response
+}
+
+// bracket-end
@@ -0,0 +1,154 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// claim-start
+// A player held by a manager of a league is claimed under the id
+// <league>:<player>, so the id index keeps two managers of a league from
+// getting the same player however their transactions interleave. Every write
+// adding players to a roster claims them in the same transaction, every write
+// taking players away releases them. Rosters filled before claims existed are
+// checked as well.
+
+var ErrPlayerTaken = errors.New("player is already owned in the league")
+
+func claimID(league string, playerID primitive.ObjectID) string {
+	return league + ":" + playerID.Hex()
+}
+func claimCollection() (*mongo.Collection, error) {
+	client, err := GetMongoClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.Database(constant.DB).Collection(constant.PLAYERCLAIMS), nil
+}
+
+// ClaimPlayers claims the players for the manager within a running
+// transaction, claims the manager already holds are kept.
+func ClaimPlayers(ctx mongo.SessionContext, league, managerID string, players []primitive.ObjectID) error {
+	if len(players) == 0 {
+		return nil
+	}
+	client, err := GetMongoClient()
+	if err != nil {
+		return err
+	}
+	db := client.Database(constant.DB)
+	managerObjectID, err := primitive.ObjectIDFromHex(managerID)
+	if err != nil {
+		return err
+	}
+
+	filter := leagueFilter(league)
+	filter["_id"] = bson.M{"$ne": managerObjectID}
+	filter["players._id"] = bson.M{"$in": players}
+	owned, err := db.Collection(constant.MANAGERS).CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if owned > 0 {
+		return ErrPlayerTaken
+	}
+
+	now := time.Now()
+	for _, player := range players {
+		_, err = db.Collection(constant.PLAYERCLAIMS).UpdateOne(ctx,
+			bson.M{"_id": claimID(league, player), "manager": managerID},
+			bson.M{"$setOnInsert": bson.M{"league": league, "player": player, "at": now}},
+			options.Update().SetUpsert(true))
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrPlayerTaken
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleasePlayers drops the manager's claims on the players within a running
+// transaction.
+func ReleasePlayers(ctx mongo.SessionContext, league, managerID string, players []primitive.ObjectID) error {
+	if len(players) == 0 {
+		return nil
+	}
+	collection, err := claimCollection()
+	if err != nil {
+		return err
+	}
+
+	ids := bson.A{}
+	for _, player := range players {
+		ids = append(ids, claimID(league, player))
+	}
+	_, err = collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}, "manager": managerID})
+	return err
+}
+
+// moveClaims releases every claim of the manager and claims the roster in
+// the league the manager moves to, within a running transaction.
+func moveClaims(ctx mongo.SessionContext, manager structs.Manager) error {
+	collection, err := claimCollection()
+	if err != nil {
+		return err
+	}
+	_, err = collection.DeleteMany(ctx, bson.M{"manager": manager.ID.Hex()})
+	if err != nil || manager.League == "" {
+		return err
+	}
+	return ClaimPlayers(ctx, manager.League, manager.ID.Hex(), playerIDs(manager.Players))
+}
+func playerIDs(players []structs.Player) []primitive.ObjectID {
+	ids := []primitive.ObjectID{}
+	for _, player := range players {
+		ids = append(ids, player.ID)
+	}
+	return ids
+}
+
+// TakenInLeague lists the players owned or claimed in a league by anyone but
+// the given manager.
+func TakenInLeague(league, exceptManager string) ([]primitive.ObjectID, error) {
+	taken := []primitive.ObjectID{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return taken, err
+	}
+	db := client.Database(constant.DB)
+
+	filter := leagueFilter(league)
+	if except, err := primitive.ObjectIDFromHex(exceptManager); err == nil {
+		filter["_id"] = bson.M{"$ne": except}
+	}
+	owned, err := db.Collection(constant.MANAGERS).Distinct(context.TODO(), "players._id", filter)
+	if err != nil {
+		return taken, err
+	}
+	claimed, err := db.Collection(constant.PLAYERCLAIMS).Distinct(context.TODO(), "player",
+		bson.M{"league": league, "manager": bson.M{"$ne": exceptManager}})
+	if err != nil {
+		return taken, err
+	}
+
+	seen := make(map[primitive.ObjectID]bool)
+	for _, id := range append(owned, claimed...) {
+		if objectID, ok := id.(primitive.ObjectID); ok && !seen[objectID] {
+			seen[objectID] = true
+			taken = append(taken, objectID)
+		}
+	}
+	return taken, nil
+}
+
+// claim-end
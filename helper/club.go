@@ -0,0 +1,221 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/request"
+	"manager-sensin/structs"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// club-start
+var ErrNoFreshClubs = errors.New("the matching clubs can't give every manager a club they haven't had this season, widen the filter")
+
+type clubStrength struct {
+	Club    string  `bson:"_id"`
+	League  string  `bson:"league"`
+	Overall float64 `bson:"overall"`
+}
+
+// GetClubStrengths rates the clubs of a league, or of every league, by the
+// average overall of their best players, sorted from the weakest club to the
+// strongest.
+func GetClubStrengths(league string, minOverall, maxOverall float64) ([]structs.ClubAssignment, error) {
+	clubs := []structs.ClubAssignment{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return clubs, err
+	}
+
+	match := bson.M{
+		"hidden":    nil,
+		"club_name": bson.M{"$nin": bson.A{nil, ""}},
+	}
+	if league != "" {
+		match["league_name"] = league
+	}
+	strength := bson.M{}
+	if minOverall > 0 {
+		strength["$gte"] = minOverall
+	}
+	if maxOverall > 0 {
+		strength["$lte"] = maxOverall
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.D{{Key: "overall", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$club_name",
+			"league":   bson.M{"$first": "$league_name"},
+			"overalls": bson.M{"$push": "$overall"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"league":  1,
+			"overall": bson.M{"$avg": bson.M{"$slice": bson.A{"$overalls", constant.CLUBSQUADSIZE}}},
+		}}},
+	}
+	if len(strength) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"overall": strength}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "overall", Value: 1}, {Key: "_id", Value: 1}}}})
+
+	strengths := []clubStrength{}
+	cursor, err := client.Database(constant.DB).Collection(constant.PLAYERS).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return clubs, err
+	}
+	if err = cursor.All(context.TODO(), &strengths); err != nil {
+		return clubs, err
+	}
+	for _, club := range strengths {
+		clubs = append(clubs, structs.ClubAssignment{Club: club.Club, League: club.League, Overall: club.Overall})
+	}
+
+	return clubs, nil
+}
+
+// BalancedClubs gives each manager a club of similar strength from clubs
+// sorted by strength, never one in the manager's used set. Any run of
+// neighbouring clubs no more than spread apart may be chosen, when none of
+// them works the closest run which does is used.
+func BalancedClubs(clubs []structs.ClubAssignment, managers []structs.Manager, used map[string]map[string]bool, spread float64) ([]structs.ClubAssignment, error) {
+	count := len(managers)
+	if count < 1 {
+		return nil, fmt.Errorf("no manager to assign a club to")
+	}
+	if len(clubs) < count {
+		return nil, fmt.Errorf("only %d clubs match for %d managers", len(clubs), count)
+	}
+
+	balanced, rest := []int{}, []int{}
+	for i := 0; i+count <= len(clubs); i++ {
+		if clubs[i+count-1].Overall-clubs[i].Overall <= spread {
+			balanced = append(balanced, i)
+		} else {
+			rest = append(rest, i)
+		}
+	}
+	shuffleInts(balanced)
+	gap := func(i int) float64 {
+		return clubs[i+count-1].Overall - clubs[i].Overall
+	}
+	sort.SliceStable(rest, func(i, j int) bool {
+		return gap(rest[i]) < gap(rest[j])
+	})
+
+	for _, start := range append(balanced, rest...) {
+		window := clubs[start : start+count]
+		if picked, ok := matchClubs(window, managers, used); ok {
+			return picked, nil
+		}
+	}
+	return nil, ErrNoFreshClubs
+}
+
+// matchClubs hands the clubs of a window to the managers in random order so
+// that nobody gets a club they already had, by augmenting paths.
+func matchClubs(window []structs.ClubAssignment, managers []structs.Manager, used map[string]map[string]bool) ([]structs.ClubAssignment, bool) {
+	holder := make([]int, len(window))
+	for i := range holder {
+		holder[i] = -1
+	}
+	order := make([]int, len(window))
+	for i := range order {
+		order[i] = i
+	}
+	shuffleInts(order)
+
+	var assign func(manager int, seen []bool) bool
+	assign = func(manager int, seen []bool) bool {
+		for _, club := range order {
+			if seen[club] || used[managers[manager].ID.Hex()][window[club].Club] {
+				continue
+			}
+			seen[club] = true
+			if holder[club] < 0 || assign(holder[club], seen) {
+				holder[club] = manager
+				return true
+			}
+		}
+		return false
+	}
+	for manager := range managers {
+		if !assign(manager, make([]bool, len(window))) {
+			return nil, false
+		}
+	}
+
+	picked := make([]structs.ClubAssignment, len(managers))
+	for club, manager := range holder {
+		picked[manager] = window[club]
+		picked[manager].Manager = managers[manager].ID.Hex()
+		picked[manager].ManagerName = managers[manager].Name
+	}
+	return picked, true
+}
+func shuffleInts(values []int) {
+	for i := len(values) - 1; i > 0; i-- {
+		j := GetRandom(0, i+1)
+		values[i], values[j] = values[j], values[i]
+	}
+}
+
+// AssignClubs gives every manager a random club of balanced strength which
+// the manager didn't have in an earlier round of the season and stores the
+// round.
+func AssignClubs(season *structs.Season, managers []structs.Manager, cr request.ClubRequest) (structs.ClubRound, error) {
+	round := structs.ClubRound{
+		Round:    len(season.ClubRounds) + 1,
+		Matchday: cr.Matchday,
+		At:       time.Now(),
+	}
+
+	used := make(map[string]map[string]bool)
+	for _, previous := range season.ClubRounds {
+		for _, assignment := range previous.Assignments {
+			if used[assignment.Manager] == nil {
+				used[assignment.Manager] = make(map[string]bool)
+			}
+			used[assignment.Manager][assignment.Club] = true
+		}
+	}
+	clubs, err := GetClubStrengths(cr.League, cr.MinOverall, cr.MaxOverall)
+	if err != nil {
+		return round, err
+	}
+	spread := cr.Spread
+	if spread <= 0 {
+		spread = constant.CLUBSPREAD
+	}
+	round.Assignments, err = BalancedClubs(clubs, managers, used, spread)
+	if err != nil {
+		return round, err
+	}
+
+	client, err := GetMongoClient()
+	if err != nil {
+		return round, err
+	}
+	// the round only lands when nobody added one since the season was read
+	update, err := client.Database(constant.DB).Collection(constant.SEASONS).UpdateOne(context.TODO(),
+		bson.M{"_id": season.ID, fmt.Sprintf("clubRounds.%d", len(season.ClubRounds)): bson.M{"$exists": false}},
+		bson.M{"$push": bson.M{"clubRounds": round}})
+	if err != nil {
+		return round, err
+	}
+	if update.MatchedCount == 0 {
+		return round, fmt.Errorf("another club round was assigned meanwhile, try again")
+	}
+	season.ClubRounds = append(season.ClubRounds, round)
+
+	return round, nil
+}
+
+// club-end
@@ -0,0 +1,291 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"manager-sensin/constant"
+	"manager-sensin/request"
+	"manager-sensin/structs"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// draft-start
+var (
+	ErrNotOnTheClock = errors.New("it is not your turn to pick")
+	ErrPickConflict  = errors.New("another pick was made meanwhile, try again")
+)
+
+// CreateDraft sets up the draft of a season. Managers pick in random order or
+// in reverse order of the standings of standingsSeason, the worst first.
+func CreateDraft(season *structs.Season, managers []string, dr request.DraftRequest, standings []structs.Standing) (structs.Draft, error) {
+	draft := structs.Draft{
+		ID:          primitive.NewObjectID(),
+		Season:      season.ID.Hex(),
+		League:      season.League,
+		Status:      constant.DRAFTACTIVE,
+		Snake:       dr.Mode != constant.DRAFTLINEAR,
+		Positions:   dr.Positions,
+		PickSeconds: dr.PickSeconds,
+		Picks:       []structs.DraftPick{},
+		CreatedAt:   time.Now(),
+	}
+	if len(draft.Positions) == 0 {
+		draft.Positions = constant.DEFAULT_DRAFT_POSITIONS
+	}
+	if draft.PickSeconds == 0 {
+		draft.PickSeconds = constant.DRAFTPICKSECONDS
+	}
+
+	draft.Order = append([]string{}, managers...)
+	if dr.Order == constant.DRAFTSTANDINGS {
+		draft.Order = reverseStandings(managers, standings)
+	} else {
+		for i := len(draft.Order) - 1; i > 0; i-- {
+			j := GetRandom(0, i+1)
+			draft.Order[i], draft.Order[j] = draft.Order[j], draft.Order[i]
+		}
+	}
+	deadline := draft.CreatedAt.Add(time.Duration(draft.PickSeconds) * time.Second)
+	draft.Deadline = &deadline
+
+	client, err := GetMongoClient()
+	if err != nil {
+		return draft, err
+	}
+	db := client.Database(constant.DB)
+	err = PrepareCollections()
+	if err != nil {
+		return draft, err
+	}
+
+	err = WithTransaction(func(ctx mongo.SessionContext) error {
+		update, err := db.Collection(constant.SEASONS).UpdateOne(ctx, bson.M{"_id": season.ID, "draft": nil},
+			bson.M{"$set": bson.M{"draft": draft.ID.Hex()}})
+		if err != nil {
+			return err
+		}
+		if update.MatchedCount == 0 {
+			return fmt.Errorf("season already has a draft")
+		}
+		_, err = db.Collection(constant.DRAFTS).InsertOne(ctx, draft)
+		return err
+	})
+	if err != nil {
+		return draft, err
+	}
+	season.Draft = draft.ID.Hex()
+
+	return draft, nil
+}
+
+// reverseStandings orders managers from the bottom of the standing up,
+// managers without a standing pick first.
+func reverseStandings(managers []string, standings []structs.Standing) []string {
+	rank := make(map[string]int)
+	for i, standing := range standings {
+		rank[standing.ID] = i + 1
+	}
+	order := []string{}
+	for _, manager := range managers {
+		if rank[manager] == 0 {
+			order = append(order, manager)
+		}
+	}
+	for i := len(standings) - 1; i >= 0; i-- {
+		if found, _ := IsExistInSlice(managers, standings[i].ID); found {
+			order = append(order, standings[i].ID)
+		}
+	}
+	return order
+}
+func GetDraftByID(id string) (structs.Draft, error) {
+	draft := structs.Draft{}
+
+	draftID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return draft, err
+	}
+	result, err := GetSingleResultByID(draftID, constant.DRAFTS)
+	if err != nil {
+		return draft, err
+	}
+
+	err = result.Decode(&draft)
+	if err != nil {
+		return draft, err
+	}
+
+	return draft, nil
+}
+
+// MakePick drafts a player for the manager on the clock. The claim on the
+// player, the pick and the roster change are written together.
+func MakePick(draft *structs.Draft, managerID string, player structs.Player, auto bool) (structs.DraftPick, error) {
+	pick := structs.DraftPick{}
+	manager, round, open := draft.OnTheClock()
+	if !open {
+		return pick, fmt.Errorf("draft is %s", constant.DRAFTCOMPLETE)
+	}
+	if manager != managerID {
+		return pick, ErrNotOnTheClock
+	}
+	if player.Hidden {
+		return pick, fmt.Errorf("%s can't be drafted", player.Name)
+	}
+
+	managerObjectID, err := primitive.ObjectIDFromHex(managerID)
+	if err != nil {
+		return pick, err
+	}
+	now := time.Now()
+	pick = structs.DraftPick{
+		Number:   len(draft.Picks) + 1,
+		Round:    round,
+		Manager:  managerID,
+		Player:   player.ID,
+		Name:     player.Name,
+		Overall:  player.Overall,
+		Position: draft.Slot(managerID, player),
+		Auto:     auto,
+		At:       now,
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+	if pick.Number == draft.TotalPicks() {
+		set["status"] = constant.DRAFTCOMPLETE
+		unset["deadline"] = ""
+	} else {
+		set["deadline"] = now.Add(time.Duration(draft.PickSeconds) * time.Second)
+	}
+	update := bson.M{"$push": bson.M{"picks": pick}, "$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	client, err := GetMongoClient()
+	if err != nil {
+		return pick, err
+	}
+	db := client.Database(constant.DB)
+	err = WithTransaction(func(ctx mongo.SessionContext) error {
+		err := ClaimPlayers(ctx, draft.League, managerID, []primitive.ObjectID{player.ID})
+		if err != nil {
+			return err
+		}
+
+		result, err := db.Collection(constant.DRAFTS).UpdateOne(ctx,
+			bson.M{"_id": draft.ID, fmt.Sprintf("picks.%d", len(draft.Picks)): bson.M{"$exists": false}}, update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrPickConflict
+		}
+
+		result, err = db.Collection(constant.MANAGERS).UpdateOne(ctx,
+			bson.M{"_id": managerObjectID, "players._id": bson.M{"$ne": player.ID}},
+			bson.M{"$push": bson.M{"players": player}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrPlayerTaken
+		}
+		return nil
+	})
+	if err != nil {
+		return pick, err
+	}
+
+	draft.Picks = append(draft.Picks, pick)
+	return pick, nil
+}
+
+// AutoPick drafts the best available player for the first open position of
+// the manager on the clock, any position when nobody plays it anymore.
+func AutoPick(draft *structs.Draft) (structs.DraftPick, error) {
+	manager, _, open := draft.OnTheClock()
+	if !open {
+		return structs.DraftPick{}, fmt.Errorf("draft is %s", constant.DRAFTCOMPLETE)
+	}
+	taken, err := TakenInLeague(draft.League, "")
+	if err != nil {
+		return structs.DraftPick{}, err
+	}
+
+	filter := bson.M{"hidden": nil, "_id": bson.M{"$nin": taken}}
+	slots := draft.OpenSlots(manager)
+	if len(slots) > 0 {
+		filter["player_positions"] = primitive.Regex{Pattern: `(^|, )` + regexp.QuoteMeta(slots[0]) + `(,|$)`}
+	}
+	player, err := bestAvailable(filter)
+	if err == mongo.ErrNoDocuments && len(slots) > 0 {
+		delete(filter, "player_positions")
+		player, err = bestAvailable(filter)
+	}
+	if err != nil {
+		return structs.DraftPick{}, err
+	}
+
+	return MakePick(draft, manager, player, true)
+}
+func bestAvailable(filter bson.M) (structs.Player, error) {
+	player := structs.Player{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return player, err
+	}
+
+	err = client.Database(constant.DB).Collection(constant.PLAYERS).FindOne(context.TODO(), filter,
+		options.FindOne().SetSort(bson.D{{Key: "overall", Value: -1}, {Key: "_id", Value: 1}})).Decode(&player)
+	return player, err
+}
+
+// RunDraftClock auto-picks for every manager whose pick clock ran out. It
+// checks the drafts every interval until the process exits.
+func RunDraftClock(interval time.Duration) {
+	for range time.Tick(interval) {
+		drafts, err := overdueDrafts()
+		if err != nil {
+			log.Println("draft clock:", err)
+			continue
+		}
+		for i := range drafts {
+			_, err = AutoPick(&drafts[i])
+			if err != nil && err != ErrPickConflict {
+				log.Println("draft clock:", drafts[i].ID.Hex(), err)
+			}
+		}
+	}
+}
+func overdueDrafts() ([]structs.Draft, error) {
+	drafts := []structs.Draft{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return drafts, err
+	}
+
+	cursor, err := client.Database(constant.DB).Collection(constant.DRAFTS).Find(context.TODO(), bson.M{
+		"status":   constant.DRAFTACTIVE,
+		"deadline": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		return drafts, err
+	}
+	if err = cursor.All(context.TODO(), &drafts); err != nil {
+		return drafts, err
+	}
+
+	return drafts, nil
+}
+
+// draft-end
@@ -0,0 +1,110 @@
+package helper
+
+import (
+	"manager-sensin/structs"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fixture-start
+// GenerateFixtures builds a round-robin schedule with the circle method.
+// Odd counts get a bye slot, home games are balanced greedily and a double
+// round-robin mirrors the first half with home and away swapped. A manager
+// listed twice gets one slot, nobody plays against themselves.
+func GenerateFixtures(managers []structs.Manager, double, shuffle bool) []structs.Fixture {
+	fixtures := []structs.Fixture{}
+	slots := make([]*structs.Manager, 0, len(managers)+1)
+	placed := make(map[primitive.ObjectID]bool)
+	for i := range managers {
+		if placed[managers[i].ID] {
+			continue
+		}
+		placed[managers[i].ID] = true
+		slots = append(slots, &managers[i])
+	}
+	if shuffle {
+		for i := len(slots) - 1; i > 0; i-- {
+			j := GetRandom(0, i+1)
+			slots[i], slots[j] = slots[j], slots[i]
+		}
+	}
+	if len(slots)%2 == 1 {
+		slots = append(slots, nil)
+	}
+	if len(slots) < 2 {
+		return fixtures
+	}
+
+	homeCount := make(map[string]int)
+	lastHome := make(map[string]bool)
+	rounds := len(slots) - 1
+	half := len(slots) / 2
+	for round := 0; round < rounds; round++ {
+		for i := 0; i < half; i++ {
+			home, away := slots[i], slots[len(slots)-1-i]
+			if home == nil || away == nil {
+				if home == nil {
+					home = away
+				}
+				fixtures = append(fixtures, structs.Fixture{
+					ID:          primitive.NewObjectID(),
+					Matchday:    round + 1,
+					Home:        home.ID.Hex(),
+					HomeManager: home.Name,
+					Bye:         true,
+				})
+				continue
+			}
+
+			homeID, awayID := home.ID.Hex(), away.ID.Hex()
+			if homeCount[homeID] > homeCount[awayID] ||
+				(homeCount[homeID] == homeCount[awayID] && lastHome[homeID] && !lastHome[awayID]) {
+				home, away = away, home
+				homeID, awayID = awayID, homeID
+			}
+			homeCount[homeID]++
+			lastHome[homeID] = true
+			lastHome[awayID] = false
+
+			fixtures = append(fixtures, structs.Fixture{
+				ID:          primitive.NewObjectID(),
+				Matchday:    round + 1,
+				Home:        homeID,
+				Away:        awayID,
+				HomeManager: home.Name,
+				AwayManager: away.Name,
+			})
+		}
+
+		// keep the first slot fixed and rotate the rest clockwise
+		last := slots[len(slots)-1]
+		copy(slots[2:], slots[1:len(slots)-1])
+		slots[1] = last
+	}
+
+	if double {
+		firstHalf := len(fixtures)
+		for _, fixture := range fixtures[:firstHalf] {
+			second := structs.Fixture{
+				ID:          primitive.NewObjectID(),
+				Matchday:    fixture.Matchday + rounds,
+				Home:        fixture.Away,
+				Away:        fixture.Home,
+				HomeManager: fixture.AwayManager,
+				AwayManager: fixture.HomeManager,
+				Bye:         fixture.Bye,
+			}
+			if fixture.Bye {
+				second.Home = fixture.Home
+				second.HomeManager = fixture.HomeManager
+				second.Away = ""
+				second.AwayManager = ""
+			}
+			fixtures = append(fixtures, second)
+		}
+	}
+
+	return fixtures
+}
+
+// fixture-end
@@ -0,0 +1,172 @@
+package helper
+
+import (
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// group-start
+// DrawGroups splits the managers into groups and creates the round-robin
+// fixtures of every group. Seeded draws treat the given order as pots, so
+// the first groupCount managers end up in different groups.
+func DrawGroups(season *structs.Season, managers []structs.Manager, groupCount int, seeded, double bool) error {
+	if groupCount < 1 || len(managers) < groupCount*2 {
+		return fmt.Errorf("%d managers can't be drawn into %d groups", len(managers), groupCount)
+	}
+	if season.Qualifiers < 1 || season.Qualifiers > len(managers)/groupCount {
+		return fmt.Errorf("%d qualifiers per group is not possible with %d groups", season.Qualifiers, groupCount)
+	}
+
+	if seeded {
+		for pot := 0; pot < len(managers); pot += groupCount {
+			end := pot + groupCount
+			if end > len(managers) {
+				end = len(managers)
+			}
+			shuffleManagers(managers[pot:end])
+		}
+	} else {
+		shuffleManagers(managers)
+	}
+
+	members := make([][]structs.Manager, groupCount)
+	for i, manager := range managers {
+		members[i%groupCount] = append(members[i%groupCount], manager)
+	}
+
+	season.Groups = []structs.Group{}
+	season.Fixtures = []structs.Fixture{}
+	season.Bracket = nil
+	for i, groupManagers := range members {
+		group := structs.Group{Name: fmt.Sprintf("Group %c", 'A'+i), Managers: []string{}}
+		for _, manager := range groupManagers {
+			group.Managers = append(group.Managers, manager.ID.Hex())
+		}
+		for _, fixture := range GenerateFixtures(groupManagers, double, false) {
+			fixture.Group = group.Name
+			season.Fixtures = append(season.Fixtures, fixture)
+		}
+		season.Groups = append(season.Groups, group)
+	}
+
+	return nil
+}
+
+func GetGroupStandings(season structs.Season, results []structs.Result) []structs.GroupStanding {
+	groupStandings := []structs.GroupStanding{}
+	for _, group := range season.Groups {
+		groupResults := []structs.Result{}
+		for _, result := range results {
+			if result.Group == group.Name {
+				groupResults = append(groupResults, result)
+			}
+		}
+		groupStandings = append(groupStandings, structs.GroupStanding{
+			Group:    group.Name,
+			Standing: GetStanding(groupResults, SeasonRules(season)),
+		})
+	}
+
+	return groupStandings
+}
+
+// CompleteGroupStage draws the knockout bracket once every group fixture has
+// been played. Group winners are seeded first, then the runners-up and so on,
+// each position ordered by points and the season tiebreakers.
+func CompleteGroupStage(season *structs.Season, results []structs.Result) (bool, error) {
+	if season.Type != constant.TOURNAMENT || season.Bracket != nil || len(season.Groups) == 0 {
+		return false, nil
+	}
+
+	groupOf := make(map[string]string)
+	for _, fixture := range season.Fixtures {
+		if fixture.Group == "" {
+			continue
+		}
+		if !fixture.Bye && fixture.Result == "" {
+			return false, nil
+		}
+		groupOf[fixture.Home] = fixture.Group
+		if !fixture.Bye {
+			groupOf[fixture.Away] = fixture.Group
+		}
+	}
+
+	qualifiers := []structs.Manager{}
+	for position := 0; position < season.Qualifiers; position++ {
+		placed := []structs.Standing{}
+		for _, groupStanding := range GetGroupStandings(*season, results) {
+			if position < len(groupStanding.Standing) {
+				placed = append(placed, groupStanding.Standing[position])
+			}
+		}
+		// managers of different groups never met, head to head tiebreakers
+		// leave them level
+		rankStanding(placed, results, SeasonRules(*season))
+		for _, standing := range placed {
+			id, err := primitive.ObjectIDFromHex(standing.ID)
+			if err != nil {
+				return false, err
+			}
+			qualifiers = append(qualifiers, structs.Manager{ID: id, Name: standing.Manager})
+		}
+	}
+
+	separateGroups(qualifiers, groupOf)
+	err := GenerateBracket(season, qualifiers, true, season.TwoLegged)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// separateGroups swaps lower seeds between first round ties so that two
+// managers of the same group don't meet again straight away when avoidable.
+func separateGroups(seeds []structs.Manager, groupOf map[string]string) {
+	size := 2
+	for size < len(seeds) {
+		size *= 2
+	}
+	order := seedOrder(size)
+
+	opponent := func(tie int) int {
+		return order[tie*2+1] - 1
+	}
+	clash := func(tie int) bool {
+		low := opponent(tie)
+		high := order[tie*2] - 1
+		return low < len(seeds) && groupOf[seeds[high].ID.Hex()] == groupOf[seeds[low].ID.Hex()]
+	}
+	fits := func(tie int, candidate structs.Manager) bool {
+		return groupOf[seeds[order[tie*2]-1].ID.Hex()] != groupOf[candidate.ID.Hex()]
+	}
+
+	for tie := 0; tie < size/2; tie++ {
+		if !clash(tie) {
+			continue
+		}
+		for other := 0; other < size/2; other++ {
+			a, b := opponent(tie), opponent(other)
+			if other == tie || b >= len(seeds) {
+				continue
+			}
+			if fits(tie, seeds[b]) && fits(other, seeds[a]) {
+				seeds[a], seeds[b] = seeds[b], seeds[a]
+				break
+			}
+		}
+	}
+}
+
+func shuffleManagers(managers []structs.Manager) {
+	for i := len(managers) - 1; i > 0; i-- {
+		j := GetRandom(0, i+1)
+		managers[i], managers[j] = managers[j], managers[i]
+	}
+}
+
+// group-end
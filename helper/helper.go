@@ -3,16 +3,18 @@ package helper
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"manager-sensin/constant"
 	"manager-sensin/request"
 	"manager-sensin/structs"
-	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -199,6 +201,46 @@ func GetPlayerByID(id string) (structs.Player, error) {
 
 	return player, nil
 }
+func GetPlayersByIDs(ids []string) ([]structs.Player, error) {
+	players := []structs.Player{}
+	objectIDs := bson.A{}
+	for _, id := range ids {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return players, err
+		}
+		objectIDs = append(objectIDs, objectID)
+	}
+
+	client, err := GetMongoClient()
+	if err != nil {
+		return players, err
+	}
+
+	cursor, err := client.Database(constant.DB).Collection(constant.PLAYERS).Find(context.TODO(), bson.M{"_id": bson.M{"$in": objectIDs}})
+	if err != nil {
+		return players, err
+	}
+	found := []structs.Player{}
+	if err = cursor.All(context.TODO(), &found); err != nil {
+		return players, err
+	}
+
+	// keep the requested order
+	byID := make(map[string]structs.Player)
+	for _, player := range found {
+		byID[player.ID.Hex()] = player
+	}
+	for _, id := range ids {
+		player, ok := byID[id]
+		if !ok {
+			return players, fmt.Errorf("player %s not found", id)
+		}
+		players = append(players, player)
+	}
+
+	return players, nil
+}
 func SearchPlayerByFilter(filter, filterOptions bson.D, limit int64) ([]structs.Player, error) {
 	var players []structs.Player
 	client, err := GetMongoClient()
@@ -282,41 +324,6 @@ func AddFilterViaFields(f *request.Filter) bson.D {
 
 	return filter
 }
-func AddFilterViaType(packType int) (bson.D, int) {
-	filter := bson.D{bson.E{Key: "hidden", Value: nil}}
-	minOverall := 60
-	maxOverall := 64
-
-	randomIndex := GetRandom(1, 11) * 100
-
-	if constant.SILVER == packType {
-		minOverall = 65
-		maxOverall = 69
-	} else if constant.PREMIUMSILVER == packType {
-		minOverall = 70
-		maxOverall = 74
-	} else if constant.GOLD == packType {
-		minOverall = 75
-		maxOverall = 79
-	} else if constant.PREMIUMGOLD == packType {
-		minOverall = 77
-		maxOverall = 83
-	} else if constant.ULTIMATEGOLD == packType {
-		minOverall = 81
-		maxOverall = 87
-	} else if constant.PRIMEGOLD == packType {
-		minOverall = 84
-		maxOverall = 99
-	}
-
-	filter = append(filter, bson.E{Key: "overall", Value: bson.D{
-		{Key: "$gte", Value: minOverall},
-		{Key: "$lte", Value: maxOverall},
-	}},
-	)
-
-	return filter, randomIndex
-}
 func UpdatePlayer(player *structs.Player) (*mongo.UpdateResult, error) {
 	result := &mongo.UpdateResult{}
 	client, err := GetMongoClient()
@@ -332,6 +339,30 @@ func UpdatePlayer(player *structs.Player) (*mongo.UpdateResult, error) {
 	return result, nil
 }
 
+// DeriveRarities stores the rarity of every player without one, players
+// starting for their club are rare and everyone else common.
+func DeriveRarities() (int64, error) {
+	client, err := GetMongoClient()
+	if err != nil {
+		return 0, err
+	}
+	collection := client.Database(constant.DB).Collection(constant.PLAYERS)
+
+	rare, err := collection.UpdateMany(context.TODO(), bson.M{
+		"rarity":        nil,
+		"club_position": bson.M{"$nin": bson.A{nil, "", constant.SUBPOSITION, constant.RESPOSITION}},
+	}, bson.M{"$set": bson.M{"rarity": constant.RARITYRARE}})
+	if err != nil {
+		return 0, err
+	}
+	common, err := collection.UpdateMany(context.TODO(), bson.M{"rarity": nil}, bson.M{"$set": bson.M{"rarity": constant.RARITYCOMMON}})
+	if err != nil {
+		return rare.ModifiedCount, err
+	}
+
+	return rare.ModifiedCount + common.ModifiedCount, nil
+}
+
 // player-end
 
 func GetEnv(key string) (string, error) {
@@ -342,8 +373,9 @@ func GetEnv(key string) (string, error) {
 	return val, nil
 }
 func GetRandom(min, max int) int {
-	rand.Seed(time.Now().UnixNano())
-	return rand.Intn(max-min) + min
+	shared.Lock()
+	defer shared.Unlock()
+	return shared.Intn(max-min) + min
 }
 func ReturnError(w http.ResponseWriter, code int, err error, message string) {
 	w.WriteHeader(code)
@@ -392,6 +424,8 @@ func CreateCollection(db *mongo.Database, collectionName string) error {
 }
 
 //crud opt for manager
+var ErrManagerExists = errors.New("a manager already exists for this account")
+
 func CreateManager(manager structs.Manager) (structs.Insert, error) {
 	insert := structs.Insert{}
 	client, err := GetMongoClient()
@@ -400,7 +434,7 @@ func CreateManager(manager structs.Manager) (structs.Insert, error) {
 	}
 
 	db := client.Database(constant.DB)
-	err = CreateCollection(db, constant.MANAGERS)
+	err = PrepareCollections()
 	if err != nil {
 		return insert, err
 	}
@@ -411,9 +445,11 @@ func CreateManager(manager structs.Manager) (structs.Insert, error) {
 		{Key: "email", Value: manager.Email},
 		{Key: "players", Value: bson.A{}},
 		{Key: "points", Value: 0},
-		{Key: "results", Value: bson.A{}},
 		{Key: "badges", Value: bson.A{}},
 	})
+	if mongo.IsDuplicateKeyError(err) {
+		return insert, ErrManagerExists
+	}
 	if err != nil {
 		return insert, err
 	}
@@ -447,6 +483,46 @@ func GetManagers() ([]structs.Manager, error) {
 
 	return managers, err
 }
+func GetManagersByIDs(ids []string) ([]structs.Manager, error) {
+	managers := []structs.Manager{}
+	objectIDs := bson.A{}
+	for _, id := range ids {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return managers, err
+		}
+		objectIDs = append(objectIDs, objectID)
+	}
+
+	client, err := GetMongoClient()
+	if err != nil {
+		return managers, err
+	}
+
+	cursor, err := client.Database(constant.DB).Collection(constant.MANAGERS).Find(context.TODO(), bson.M{"_id": bson.M{"$in": objectIDs}})
+	if err != nil {
+		return managers, err
+	}
+	found := []structs.Manager{}
+	if err = cursor.All(context.TODO(), &found); err != nil {
+		return managers, err
+	}
+
+	// keep the requested order, draws use it as seeding
+	byID := make(map[string]structs.Manager)
+	for _, manager := range found {
+		byID[manager.ID.Hex()] = manager
+	}
+	for _, id := range ids {
+		manager, ok := byID[id]
+		if !ok {
+			return managers, fmt.Errorf("manager %s not found", id)
+		}
+		managers = append(managers, manager)
+	}
+
+	return managers, nil
+}
 func GetManager(id string) (structs.Manager, error) {
 	manager := structs.Manager{}
 
@@ -488,7 +564,11 @@ func UpdateManager(man *structs.Manager) (*mongo.UpdateResult, error) {
 		return result, err
 	}
 
-	result, err = client.Database(constant.DB).Collection(constant.MANAGERS).ReplaceOne(context.TODO(), bson.M{"_id": man.ID}, man)
+	update, err := fieldsUpdate(man)
+	if err != nil {
+		return result, err
+	}
+	result, err = client.Database(constant.DB).Collection(constant.MANAGERS).UpdateOne(context.TODO(), bson.M{"_id": man.ID}, update)
 	if err != nil {
 		return result, err
 	}
@@ -496,6 +576,79 @@ func UpdateManager(man *structs.Manager) (*mongo.UpdateResult, error) {
 	return result, nil
 }
 
+func SetManagerRole(managerID primitive.ObjectID, role string) (structs.Manager, error) {
+	return findAndUpdateManager(bson.M{"_id": managerID}, managerID, bson.M{"$set": bson.M{"role": role}})
+}
+
+// AddManagerPlayer pushes a player onto the roster unless it is already
+// there, without touching the rest of the manager document. The player is
+// claimed in the manager's league in the same transaction.
+func AddManagerPlayer(manager structs.Manager, player structs.Player) (structs.Manager, error) {
+	ids := []primitive.ObjectID{player.ID}
+	return updateRoster(manager.ID, bson.M{"_id": manager.ID, "players._id": bson.M{"$ne": player.ID}},
+		bson.M{"$push": bson.M{"players": player}}, func(ctx mongo.SessionContext) error {
+			return ClaimPlayers(ctx, manager.League, manager.ID.Hex(), ids)
+		})
+}
+func RemoveManagerPlayer(manager structs.Manager, playerID primitive.ObjectID) (structs.Manager, error) {
+	ids := []primitive.ObjectID{playerID}
+	return updateRoster(manager.ID, bson.M{"_id": manager.ID},
+		bson.M{"$pull": bson.M{"players": bson.M{"_id": playerID}}}, func(ctx mongo.SessionContext) error {
+			return ReleasePlayers(ctx, manager.League, manager.ID.Hex(), ids)
+		})
+}
+
+// updateRoster changes a roster together with the league claims.
+func updateRoster(managerID primitive.ObjectID, filter, update bson.M, claims func(ctx mongo.SessionContext) error) (structs.Manager, error) {
+	manager := structs.Manager{}
+	err := PrepareCollections()
+	if err != nil {
+		return manager, err
+	}
+	client, err := GetMongoClient()
+	if err != nil {
+		return manager, err
+	}
+
+	err = WithTransaction(func(ctx mongo.SessionContext) error {
+		err := claims(ctx)
+		if err != nil {
+			return err
+		}
+		err = client.Database(constant.DB).Collection(constant.MANAGERS).FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&manager)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return manager, err
+	}
+	if manager.ID.IsZero() {
+		return GetManagerByID(managerID.Hex())
+	}
+	return manager, nil
+}
+func findAndUpdateManager(filter bson.M, managerID primitive.ObjectID, update bson.M) (structs.Manager, error) {
+	manager := structs.Manager{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return manager, err
+	}
+
+	err = client.Database(constant.DB).Collection(constant.MANAGERS).FindOneAndUpdate(context.TODO(), filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&manager)
+	if err == mongo.ErrNoDocuments {
+		return GetManagerByID(managerID.Hex())
+	}
+	if err != nil {
+		return manager, err
+	}
+
+	return manager, nil
+}
+
 //manager-end
 
 //crud opt for season
@@ -512,12 +665,19 @@ func CreateSeason(season structs.Season) (structs.Insert, error) {
 		return insert, err
 	}
 
-	doc, err := db.Collection(constant.SEASONS).InsertOne(context.TODO(), bson.D{
+	fields := bson.D{
 		{Key: "type", Value: season.Type},
 		{Key: "title", Value: season.Title},
-		{Key: "results", Value: bson.A{}},
-		{Key: "isActive", Value: true},
-	})
+		{Key: "owner", Value: season.Owner},
+		{Key: "league", Value: season.League},
+		{Key: "isActive", Value: season.Status == constant.STATUSACTIVE},
+		{Key: "status", Value: season.Status},
+		{Key: "rules", Value: season.Rules},
+	}
+	if season.Meetings > 0 {
+		fields = append(fields, bson.E{Key: "meetings", Value: season.Meetings})
+	}
+	doc, err := db.Collection(constant.SEASONS).InsertOne(context.TODO(), fields)
 	if err != nil {
 		return insert, err
 	}
@@ -534,20 +694,23 @@ func CreateSeason(season structs.Season) (structs.Insert, error) {
 
 	return insert, nil
 }
-func GetSeasons() ([]request.SeasonResponse, error) {
+func GetSeasons(league string) ([]request.SeasonResponse, error) {
 	var seasons []request.SeasonResponse
 	client, err := GetMongoClient()
 	if err != nil {
 		return seasons, err
 	}
 
-	cursor, err := client.Database(constant.DB).Collection(constant.SEASONS).Find(context.TODO(), bson.M{}, nil)
+	cursor, err := client.Database(constant.DB).Collection(constant.SEASONS).Find(context.TODO(), leagueFilter(league), nil)
 	if err != nil {
 		return seasons, err
 	}
 	if err = cursor.All(context.TODO(), &seasons); err != nil {
 		return seasons, err
 	}
+	for i := range seasons {
+		seasons[i].Status = SeasonStatus(seasons[i].Status, seasons[i].IsActive)
+	}
 
 	return seasons, err
 }
@@ -567,6 +730,7 @@ func GetSeasonByID(id string) (structs.Season, error) {
 	if err != nil {
 		return season, err
 	}
+	season.Status = SeasonStatus(season.Status, season.IsActive)
 
 	return season, nil
 }
@@ -577,213 +741,282 @@ func UpdateSeason(season *structs.Season) (*mongo.UpdateResult, error) {
 		return result, err
 	}
 
-	result, err = client.Database(constant.DB).Collection(constant.SEASONS).ReplaceOne(context.TODO(), bson.M{"_id": season.ID}, season)
+	update, err := fieldsUpdate(season)
+	if err != nil {
+		return result, err
+	}
+	result, err = client.Database(constant.DB).Collection(constant.SEASONS).UpdateOne(context.TODO(), bson.M{"_id": season.ID}, update)
 	if err != nil {
 		return result, err
 	}
 
 	return result, nil
 }
-func GetStanding(results []structs.Result) []structs.Standing {
+
+// fieldsUpdate writes every field of the struct v points to and unsets the
+// empty ones, fields the struct doesn't know, like results embedded before
+// -migrate-results ran, stay untouched.
+func fieldsUpdate(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	document := bson.M{}
+	err = bson.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	set, unset := bson.M{}, bson.M{}
+	fields := reflect.TypeOf(v).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		name := strings.Split(fields.Field(i).Tag.Get("bson"), ",")[0]
+		if name == "" {
+			name = strings.ToLower(fields.Field(i).Name)
+		}
+		if name == "-" || name == "_id" {
+			continue
+		}
+		if value, found := document[name]; found {
+			set[name] = value
+		} else {
+			unset[name] = ""
+		}
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
+}
+func SeasonRules(season structs.Season) structs.Rules {
+	if season.Rules == nil {
+		return structs.Rules{
+			Win:         3,
+			Draw:        1,
+			Loss:        0,
+			Tiebreakers: constant.DEFAULT_TIEBREAKERS,
+		}
+	}
+	return *season.Rules
+}
+func ValidateRules(rules *structs.Rules) error {
+	if rules == nil {
+		return nil
+	}
+	if rules.Win < rules.Draw || rules.Draw < rules.Loss {
+		return fmt.Errorf("a win should be worth at least a draw and a draw at least a loss")
+	}
+	for _, tiebreaker := range rules.Tiebreakers {
+		found, _ := IsExistInSlice(constant.TIEBREAKERS, tiebreaker)
+		if !found {
+			return fmt.Errorf("unknown tiebreaker %s", tiebreaker)
+		}
+	}
+	return nil
+}
+func GetStanding(results []structs.Result, rules structs.Rules) []structs.Standing {
 	standingMap := make(map[string]*structs.Standing)
 	for _, result := range results {
-		if result.Score[0] > result.Score[1] {
-			_, found := standingMap[result.HomeManager]
-			if found {
-				standingMap[result.HomeManager].Set(structs.Standing{
-					Manager: result.HomeManager,
-					Points:  3,
-					Played:  1,
-					Won:     1,
-					GF:      result.Score[0],
-					GA:      result.Score[1],
-					GD:      result.Score[0] - result.Score[1],
-					Form:    []string{"W"},
-				})
-			} else {
-				standingMap[result.HomeManager] = &structs.Standing{
-					Manager: result.HomeManager,
-					Points:  3,
-					Played:  1,
-					Won:     1,
-					GF:      result.Score[0],
-					GA:      result.Score[1],
-					GD:      result.Score[0] - result.Score[1],
-					Form:    []string{"W"},
-				}
-			}
-			_, foundAway := standingMap[result.AwayManager]
-			if foundAway {
-				standingMap[result.AwayManager].Set(structs.Standing{
-					Manager: result.AwayManager,
-					Played:  1,
-					Lost:    1,
-					GA:      result.Score[0],
-					GF:      result.Score[1],
-					GD:      result.Score[1] - result.Score[0],
-					Form:    []string{"L"},
-				})
-			} else {
-				standingMap[result.AwayManager] = &structs.Standing{
-					Manager: result.AwayManager,
-					Played:  1,
-					Lost:    1,
-					GA:      result.Score[0],
-					GF:      result.Score[1],
-					GD:      result.Score[1] - result.Score[0],
-					Form:    []string{"L"},
-				}
-			}
-		} else if result.Score[1] > result.Score[0] {
-			_, found := standingMap[result.HomeManager]
-			if found {
-				standingMap[result.HomeManager].Set(structs.Standing{
-					Manager: result.HomeManager,
-					Played:  1,
-					Lost:    1,
-					GF:      result.Score[0],
-					GA:      result.Score[1],
-					GD:      result.Score[0] - result.Score[1],
-					Form:    []string{"L"},
-				})
-			} else {
-				standingMap[result.HomeManager] = &structs.Standing{
-					Manager: result.HomeManager,
-					Played:  1,
-					Lost:    1,
-					GF:      result.Score[0],
-					GA:      result.Score[1],
-					GD:      result.Score[0] - result.Score[1],
-					Form:    []string{"L"},
-				}
-			}
-			_, foundAway := standingMap[result.AwayManager]
-			if foundAway {
-				standingMap[result.AwayManager].Set(structs.Standing{
-					Manager: result.AwayManager,
-					Played:  1,
-					Points:  3,
-					Won:     1,
-					GA:      result.Score[0],
-					GF:      result.Score[1],
-					GD:      result.Score[1] - result.Score[0],
-					Form:    []string{"W"},
-				})
-			} else {
-				standingMap[result.AwayManager] = &structs.Standing{
-					Manager: result.AwayManager,
-					Played:  1,
-					Points:  3,
-					Won:     1,
-					GA:      result.Score[0],
-					GF:      result.Score[1],
-					GD:      result.Score[1] - result.Score[0],
-					Form:    []string{"W"},
-				}
-			}
-		} else {
-			_, found := standingMap[result.HomeManager]
+		home := resultStanding(result.Home, result.HomeManager, result.Score[0], result.Score[1], rules)
+		away := resultStanding(result.Away, result.AwayManager, result.Score[1], result.Score[0], rules)
+		home.FairPlay = fairPlay(result, 0)
+		away.FairPlay = fairPlay(result, 1)
+
+		for _, s := range []structs.Standing{home, away} {
+			_, found := standingMap[s.ID]
 			if found {
-				standingMap[result.HomeManager].Set(structs.Standing{
-					Manager: result.HomeManager,
-					Played:  1,
-					Draw:    1,
-					Points:  1,
-					GF:      result.Score[0],
-					GA:      result.Score[1],
-					GD:      result.Score[0] - result.Score[1],
-					Form:    []string{"D"},
-				})
+				standingMap[s.ID].Set(s)
 			} else {
-				standingMap[result.HomeManager] = &structs.Standing{
-					Manager: result.HomeManager,
-					Played:  1,
-					Points:  1,
-					Draw:    1,
-					GF:      result.Score[0],
-					GA:      result.Score[1],
-					GD:      result.Score[0] - result.Score[1],
-					Form:    []string{"D"},
-				}
-			}
-			_, foundAway := standingMap[result.AwayManager]
-			if foundAway {
-				standingMap[result.AwayManager].Set(structs.Standing{
-					Manager: result.AwayManager,
-					Played:  1,
-					Points:  1,
-					Draw:    1,
-					GA:      result.Score[0],
-					GF:      result.Score[1],
-					GD:      result.Score[1] - result.Score[0],
-					Form:    []string{"D"},
-				})
-			} else {
-				standingMap[result.AwayManager] = &structs.Standing{
-					Manager: result.AwayManager,
-					Played:  1,
-					Points:  1,
-					Draw:    1,
-					GA:      result.Score[0],
-					GF:      result.Score[1],
-					GD:      result.Score[1] - result.Score[0],
-					Form:    []string{"D"},
-				}
+				entry := s
+				standingMap[s.ID] = &entry
 			}
 		}
 	}
+
 	standing := []structs.Standing{}
 	for _, s := range standingMap {
 		standing = append(standing, *s)
 	}
+	rankStanding(standing, results, rules)
 
+	return standing
+}
+
+// rankStanding orders rows by points and every run of rows level on points
+// by the season tiebreakers.
+func rankStanding(standing []structs.Standing, results []structs.Result, rules structs.Rules) {
 	sort.Slice(standing, func(i, j int) bool {
 		return standing[i].Points > standing[j].Points
 	})
-	return standing
+
+	for start := 0; start < len(standing); {
+		end := start + 1
+		for end < len(standing) && standing[end].Points == standing[start].Points {
+			end++
+		}
+		if end-start > 1 {
+			breakTie(standing[start:end], results, rules)
+		}
+		start = end
+	}
 }
-func GetStats(results []structs.Result) []structs.Stats {
-	statsMap := make(map[string]*structs.Stats)
+func resultStanding(id, manager string, gf, ga int, rules structs.Rules) structs.Standing {
+	s := structs.Standing{
+		ID:      id,
+		Manager: manager,
+		Played:  1,
+		GF:      gf,
+		GA:      ga,
+		GD:      gf - ga,
+	}
+	if gf > ga {
+		s.Won = 1
+		s.Points = rules.Win
+		s.Form = []string{"W"}
+	} else if gf < ga {
+		s.Lost = 1
+		s.Points = rules.Loss
+		s.Form = []string{"L"}
+	} else {
+		s.Draw = 1
+		s.Points = rules.Draw
+		s.Form = []string{"D"}
+	}
+	return s
+}
+
+// fairPlay counts a yellow card as one and a red card as three points,
+// the fewer the better.
+func fairPlay(result structs.Result, side int) int {
+	points := 0
+	if len(result.YellowCards) == 2 {
+		points += result.YellowCards[side]
+	}
+	if len(result.RedCards) == 2 {
+		points += result.RedCards[side] * 3
+	}
+	return points
+}
+
+// breakTie orders managers level on points by the season tiebreakers, falling
+// back to the manager name so the order never depends on map iteration. The
+// tiebreaker which put a manager above the next one is reported on the row.
+func breakTie(tied []structs.Standing, results []structs.Result, rules structs.Rules) {
+	members := make(map[string]bool)
+	for _, s := range tied {
+		members[s.ID] = true
+	}
+
+	h2h := make(map[string]*structs.Standing)
 	for _, result := range results {
-		for _, scorer := range result.HomeScorers {
-			_, found := statsMap[scorer.Player.Name]
+		if !members[result.Home] || !members[result.Away] {
+			continue
+		}
+		home := resultStanding(result.Home, result.HomeManager, result.Score[0], result.Score[1], rules)
+		away := resultStanding(result.Away, result.AwayManager, result.Score[1], result.Score[0], rules)
+		for _, s := range []structs.Standing{home, away} {
+			_, found := h2h[s.ID]
 			if found {
-				statsMap[scorer.Player.Name].Count += scorer.Count
+				h2h[s.ID].Set(s)
 			} else {
-				statsMap[scorer.Player.Name] = &structs.Stats{
-					Manager: result.HomeManager,
-					Player:  scorer.Player.Name,
-					FaceUrl: scorer.Player.FaceUrl,
-					Count:   scorer.Count,
-				}
+				entry := s
+				h2h[s.ID] = &entry
 			}
 		}
+	}
 
-		for _, scorer := range result.AwayScorers {
-			_, found := statsMap[scorer.Player.Name]
-			if found {
-				statsMap[scorer.Player.Name].Count += scorer.Count
-			} else {
-				statsMap[scorer.Player.Name] = &structs.Stats{
-					Manager: result.AwayManager,
-					Player:  scorer.Player.Name,
-					FaceUrl: scorer.Player.FaceUrl,
-					Count:   scorer.Count,
-				}
+	value := func(s structs.Standing, tiebreaker string) int {
+		switch tiebreaker {
+		case constant.GOALDIFFERENCE:
+			return s.GD
+		case constant.GOALSFOR:
+			return s.GF
+		case constant.HEADTOHEADPOINTS:
+			if h2h[s.ID] != nil {
+				return h2h[s.ID].Points
+			}
+		case constant.HEADTOHEADGD:
+			if h2h[s.ID] != nil {
+				return h2h[s.ID].GD
 			}
+		case constant.WINS:
+			return s.Won
+		case constant.FAIRPLAY:
+			return -s.FairPlay
 		}
+		return 0
 	}
-
-	stats := []structs.Stats{}
-	for _, s := range statsMap {
-		stats = append(stats, *s)
+	decide := func(a, b structs.Standing) (bool, string) {
+		for _, tiebreaker := range rules.Tiebreakers {
+			va, vb := value(a, tiebreaker), value(b, tiebreaker)
+			if va != vb {
+				return va > vb, tiebreaker
+			}
+		}
+		if a.Manager != b.Manager {
+			return a.Manager < b.Manager, constant.MANAGERNAME
+		}
+		return a.ID < b.ID, constant.MANAGERNAME
 	}
 
-	sort.Slice(stats, func(i, j int) bool {
-		return stats[i].Count > stats[j].Count
+	sort.SliceStable(tied, func(i, j int) bool {
+		before, _ := decide(tied[i], tied[j])
+		return before
 	})
+	for i := 0; i < len(tied)-1; i++ {
+		_, tied[i].Tiebreaker = decide(tied[i], tied[i+1])
+	}
+}
+// GetSeasonStats totals the goals of every scorer in a season, both sides of
+// each result are flattened into one list before grouping by player.
+func GetSeasonStats(seasonID string) ([]structs.Stats, error) {
+	stats := []structs.Stats{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return stats, err
+	}
+
+	scorers := func(side, manager string) bson.M {
+		return bson.M{"$map": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$" + side, bson.A{}}},
+			"as":    "scorer",
+			"in": bson.M{
+				"manager": "$" + manager,
+				"player":  "$$scorer.player.short_name",
+				"faceUrl": "$$scorer.player.player_face_url",
+				"count":   "$$scorer.count",
+			},
+		}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"season": seasonID}}},
+		{{Key: "$project", Value: bson.M{"scorers": bson.M{"$concatArrays": bson.A{
+			scorers("homescorers", "homeManager"),
+			scorers("awayscorers", "awayManager"),
+		}}}}},
+		{{Key: "$unwind", Value: "$scorers"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     "$scorers.player",
+			"manager": bson.M{"$first": "$scorers.manager"},
+			"faceUrl": bson.M{"$first": "$scorers.faceUrl"},
+			"count":   bson.M{"$sum": "$scorers.count"},
+		}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "player": "$_id", "manager": 1, "faceUrl": 1, "count": 1}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "player", Value: 1}}}},
+	}
+
+	cursor, err := client.Database(constant.DB).Collection(constant.RESULTS).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return stats, err
+	}
+	err = cursor.All(context.TODO(), &stats)
+	if err != nil {
+		return stats, err
+	}
 
-	return stats
+	return stats, nil
 }
 
 //season-end
@@ -811,6 +1044,13 @@ func CreateResult(result structs.Result) (structs.Insert, error) {
 		{Key: "homeManager", Value: result.HomeManager},
 		{Key: "awayManager", Value: result.AwayManager},
 		{Key: "score", Value: result.Score},
+		{Key: "fixture", Value: result.Fixture},
+		{Key: "matchday", Value: result.Matchday},
+		{Key: "extraTime", Value: result.ExtraTime},
+		{Key: "penalties", Value: result.Penalties},
+		{Key: "group", Value: result.Group},
+		{Key: "yellowCards", Value: result.YellowCards},
+		{Key: "redCards", Value: result.RedCards},
 		{Key: "homescorers", Value: result.HomeScorers},
 		{Key: "awayscorers", Value: result.AwayScorers},
 	})
@@ -830,3 +1070,114 @@ func CreateResult(result structs.Result) (structs.Insert, error) {
 
 	return insert, nil
 }
+func GetResultByID(id string) (structs.Result, error) {
+	result := structs.Result{}
+
+	resultID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return result, err
+	}
+	single, err := GetSingleResultByID(resultID, constant.RESULTS)
+	if err != nil {
+		return result, err
+	}
+
+	err = single.Decode(&result)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+func GetSeasonResults(seasonID string) ([]structs.Result, error) {
+	return findResults(bson.M{"season": seasonID})
+}
+func GetManagerResults(managerID string) ([]structs.Result, error) {
+	return findResults(bson.M{"$or": bson.A{bson.M{"home": managerID}, bson.M{"away": managerID}}})
+}
+func findResults(filter bson.M) ([]structs.Result, error) {
+	results := []structs.Result{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return results, err
+	}
+
+	cursor, err := client.Database(constant.DB).Collection(constant.RESULTS).Find(context.TODO(), filter,
+		options.Find().SetSort(bson.D{{Key: "matchday", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return results, err
+	}
+	err = cursor.All(context.TODO(), &results)
+	if err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+// CountMeetings counts the results between two managers in a season,
+// regardless of who played at home.
+func CountMeetings(seasonID, a, b string) (int, error) {
+	client, err := GetMongoClient()
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := client.Database(constant.DB).Collection(constant.RESULTS).CountDocuments(context.TODO(), bson.M{
+		"season": seasonID,
+		"$or": bson.A{
+			bson.M{"home": a, "away": b},
+			bson.M{"home": b, "away": a},
+		},
+	})
+	return int(count), err
+}
+func GetScorers(scorers []request.ScorerRequest, home, away structs.Manager) ([]structs.Scorer, []structs.Scorer) {
+	homeScorers := []structs.Scorer{}
+	awayScorers := []structs.Scorer{}
+	for _, scorer := range scorers {
+		if scorer.Manager == home.ID.Hex() {
+			for _, player := range home.Players {
+				if scorer.Player == player.ID.Hex() {
+					homeScorers = append(homeScorers, structs.Scorer{
+						Player: player,
+						Count:  scorer.Count,
+					})
+					break
+				}
+			}
+		} else {
+			for _, player := range away.Players {
+				if scorer.Player == player.ID.Hex() {
+					awayScorers = append(awayScorers, structs.Scorer{
+						Player: player,
+						Count:  scorer.Count,
+					})
+					break
+				}
+			}
+		}
+	}
+
+	return homeScorers, awayScorers
+}
+
+//result-audit
+func GetResultAudits(resultID string) ([]structs.ResultAudit, error) {
+	audits := []structs.ResultAudit{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return audits, err
+	}
+
+	cursor, err := client.Database(constant.DB).Collection(constant.RESULTAUDITS).Find(context.TODO(), bson.M{"result": resultID},
+		options.Find().SetSort(bson.D{{Key: "at", Value: 1}}))
+	if err != nil {
+		return audits, err
+	}
+	if err = cursor.All(context.TODO(), &audits); err != nil {
+		return audits, err
+	}
+
+	return audits, nil
+}
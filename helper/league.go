@@ -0,0 +1,272 @@
+package helper
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"math/big"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// league-start
+// CreateLeague stores a new league with a fresh invite code and moves its
+// owner into it.
+func CreateLeague(name string, owner structs.Manager) (structs.League, error) {
+	league := structs.League{
+		ID:        primitive.NewObjectID(),
+		Name:      name,
+		Owner:     owner.ID.Hex(),
+		CreatedAt: time.Now(),
+	}
+	client, err := GetMongoClient()
+	if err != nil {
+		return league, err
+	}
+	db := client.Database(constant.DB)
+	err = PrepareCollections()
+	if err != nil {
+		return league, err
+	}
+
+	league.InviteCode, err = NewInviteCode()
+	if err != nil {
+		return league, err
+	}
+
+	update := bson.M{"league": league.ID.Hex()}
+	if ManagerRole(owner) == constant.ROLEMANAGER {
+		update["role"] = constant.ROLEOWNER
+	}
+	err = WithTransaction(func(ctx mongo.SessionContext) error {
+		_, err := db.Collection(constant.LEAGUES).InsertOne(ctx, league)
+		if err != nil {
+			return err
+		}
+		_, err = db.Collection(constant.MANAGERS).UpdateOne(ctx, bson.M{"_id": owner.ID}, bson.M{"$set": update})
+		if err != nil {
+			return err
+		}
+		owner.League = league.ID.Hex()
+		return moveClaims(ctx, owner)
+	})
+	return league, err
+}
+func GetLeagueByID(id string) (structs.League, error) {
+	league := structs.League{}
+
+	leagueID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return league, err
+	}
+	result, err := GetSingleResultByID(leagueID, constant.LEAGUES)
+	if err != nil {
+		return league, err
+	}
+
+	err = result.Decode(&league)
+	if err != nil {
+		return league, err
+	}
+
+	return league, nil
+}
+func GetLeagueByCode(code string) (structs.League, error) {
+	league := structs.League{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return league, err
+	}
+
+	err = client.Database(constant.DB).Collection(constant.LEAGUES).FindOne(context.TODO(), bson.M{"inviteCode": code}).Decode(&league)
+	if err != nil {
+		return league, err
+	}
+
+	return league, nil
+}
+
+// RotateInviteCode replaces the invite code so old codes stop working.
+func RotateInviteCode(league *structs.League) error {
+	code, err := NewInviteCode()
+	if err != nil {
+		return err
+	}
+	client, err := GetMongoClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Database(constant.DB).Collection(constant.LEAGUES).UpdateOne(context.TODO(), bson.M{"_id": league.ID},
+		bson.M{"$set": bson.M{"inviteCode": code}})
+	if err != nil {
+		return err
+	}
+	league.InviteCode = code
+	return nil
+}
+func NewInviteCode() (string, error) {
+	code := make([]byte, constant.INVITECODELENGTH)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(constant.INVITECODECHARS))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = constant.INVITECODECHARS[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// SetManagerLeague moves a manager into a league, an empty league removes
+// them from their current one. The roster's claims move along, joining fails
+// with ErrPlayerTaken when a member of the league owns one of the players.
+func SetManagerLeague(managerID primitive.ObjectID, league string) (structs.Manager, error) {
+	manager := structs.Manager{}
+	update := bson.M{"$set": bson.M{"league": league}}
+	if league == "" {
+		update = bson.M{"$unset": bson.M{"league": ""}}
+	}
+	err := PrepareCollections()
+	if err != nil {
+		return manager, err
+	}
+	client, err := GetMongoClient()
+	if err != nil {
+		return manager, err
+	}
+
+	err = WithTransaction(func(ctx mongo.SessionContext) error {
+		err := client.Database(constant.DB).Collection(constant.MANAGERS).FindOneAndUpdate(ctx, bson.M{"_id": managerID}, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&manager)
+		if err != nil {
+			return err
+		}
+		return moveClaims(ctx, manager)
+	})
+	return manager, err
+}
+func GetLeagueManagers(league string) ([]structs.Manager, error) {
+	managers := []structs.Manager{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return managers, err
+	}
+
+	cursor, err := client.Database(constant.DB).Collection(constant.MANAGERS).Find(context.TODO(), leagueFilter(league),
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return managers, err
+	}
+	if err = cursor.All(context.TODO(), &managers); err != nil {
+		return managers, err
+	}
+
+	return managers, nil
+}
+
+// leagueFilter matches the documents of a league, documents created before
+// leagues existed only match the empty league.
+func leagueFilter(league string) bson.M {
+	if league == "" {
+		return bson.M{"league": bson.M{"$in": bson.A{nil, ""}}}
+	}
+	return bson.M{"league": league}
+}
+
+// InLeague tells whether the caller may see data of the league, admins see
+// every league.
+func InLeague(caller structs.Manager, league string) bool {
+	return HasRole(caller, constant.ROLEADMIN) || caller.League == league
+}
+
+// LeagueSeason loads a season the caller may see. Seasons of other leagues
+// are reported as missing so their existence doesn't leak.
+func LeagueSeason(caller structs.Manager, id string) (structs.Season, error) {
+	season, err := GetSeasonByID(id)
+	if err != nil {
+		return season, err
+	}
+	if !InLeague(caller, season.League) {
+		return structs.Season{}, fmt.Errorf("season %s not found", id)
+	}
+	return season, nil
+}
+
+// LeagueManager loads a manager of the caller's league.
+func LeagueManager(caller structs.Manager, id string) (structs.Manager, error) {
+	manager, err := GetManagerByID(id)
+	if err != nil {
+		return manager, err
+	}
+	if !InLeague(caller, manager.League) {
+		return structs.Manager{}, fmt.Errorf("manager %s not found", id)
+	}
+	return manager, nil
+}
+
+// LeagueManagers loads managers in the given order and fails when one of them
+// is not part of the league.
+func LeagueManagers(league string, ids []string) ([]structs.Manager, error) {
+	managers, err := GetManagersByIDs(ids)
+	if err != nil {
+		return managers, err
+	}
+	for _, manager := range managers {
+		if manager.League != league {
+			return managers, fmt.Errorf("%s is not a member of the league", manager.Name)
+		}
+	}
+	return managers, nil
+}
+
+// AssignDefaultLeague puts every manager and season created before leagues
+// existed into a new league named name. The first admin, or else the first
+// manager, becomes its owner.
+func AssignDefaultLeague(name string) (structs.League, int64, error) {
+	league := structs.League{}
+	managers, err := GetLeagueManagers("")
+	if err != nil {
+		return league, 0, err
+	}
+	if len(managers) == 0 {
+		return league, 0, fmt.Errorf("every manager already belongs to a league")
+	}
+
+	owner := managers[0]
+	for _, manager := range managers {
+		if HasRole(manager, constant.ROLEADMIN) {
+			owner = manager
+			break
+		}
+	}
+	league, err = CreateLeague(name, owner)
+	if err != nil {
+		return league, 0, err
+	}
+
+	client, err := GetMongoClient()
+	if err != nil {
+		return league, 0, err
+	}
+	db := client.Database(constant.DB)
+	moved, err := db.Collection(constant.MANAGERS).UpdateMany(context.TODO(), leagueFilter(""),
+		bson.M{"$set": bson.M{"league": league.ID.Hex()}})
+	if err != nil {
+		return league, 0, err
+	}
+	_, err = db.Collection(constant.SEASONS).UpdateMany(context.TODO(), leagueFilter(""),
+		bson.M{"$set": bson.M{"league": league.ID.Hex()}})
+	if err != nil {
+		return league, 0, err
+	}
+
+	return league, moved.ModifiedCount + 1, nil
+}
+
+// league-end
@@ -0,0 +1,214 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ledger-start
+var ErrInsufficientPoints = errors.New("check manager point to process this action")
+
+// ChangePoints applies the amounts of the entries to the manager's points
+// with a single $inc and records the entries in the same transaction, each
+// with the balance right after it. The debits carry a balance guard in the
+// filter, so concurrent spending can never go below zero. Extra conditions
+// and update operators, like pushing a player bought with the points, are
+// applied in the same write. A short balance returns ErrInsufficientPoints,
+// a condition which doesn't hold mongo.ErrNoDocuments.
+func ChangePoints(managerID primitive.ObjectID, condition, update bson.M, entries ...structs.LedgerEntry) (structs.Manager, error) {
+	manager := structs.Manager{}
+	err := PrepareCollections()
+	if err != nil {
+		return manager, err
+	}
+
+	err = WithTransaction(func(ctx mongo.SessionContext) error {
+		manager, err = ApplyPoints(ctx, managerID, condition, update, entries...)
+		return err
+	})
+	return manager, err
+}
+
+// ApplyPoints is ChangePoints within a running transaction, so points can
+// change together with other writes.
+func ApplyPoints(ctx mongo.SessionContext, managerID primitive.ObjectID, condition, update bson.M, entries ...structs.LedgerEntry) (structs.Manager, error) {
+	manager := structs.Manager{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return manager, err
+	}
+	db := client.Database(constant.DB)
+
+	total, debit := 0, 0
+	for _, entry := range entries {
+		total += entry.Amount
+		if entry.Amount < 0 {
+			debit -= entry.Amount
+		}
+	}
+
+	filter := bson.M{"_id": managerID}
+	for key, value := range condition {
+		filter[key] = value
+	}
+	if debit > 0 {
+		filter["points"] = bson.M{"$gte": debit}
+	}
+	if update == nil {
+		update = bson.M{}
+	}
+	update["$inc"] = bson.M{"points": total}
+
+	err = db.Collection(constant.MANAGERS).FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&manager)
+	if err == mongo.ErrNoDocuments && debit > 0 {
+		// tell a short balance from a condition which doesn't hold
+		delete(filter, "points")
+		matched, err := db.Collection(constant.MANAGERS).CountDocuments(ctx, filter)
+		if err != nil {
+			return manager, err
+		}
+		if matched > 0 {
+			return manager, ErrInsufficientPoints
+		}
+		return manager, mongo.ErrNoDocuments
+	}
+	if err != nil {
+		return manager, err
+	}
+
+	balance := manager.Points - total
+	for _, entry := range entries {
+		balance += entry.Amount
+		entry.Manager = managerID.Hex()
+		entry.Balance = balance
+		if entry.At.IsZero() {
+			entry.At = time.Now()
+		}
+		_, err = db.Collection(constant.LEDGER).InsertOne(ctx, entry)
+		if err != nil {
+			return manager, err
+		}
+	}
+	return manager, nil
+}
+
+// GetLedger returns one page of a manager's ledger, newest entry first, and
+// the total number of entries.
+func GetLedger(managerID string, page, size int) ([]structs.LedgerEntry, int64, error) {
+	entries := []structs.LedgerEntry{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return entries, 0, err
+	}
+	collection := client.Database(constant.DB).Collection(constant.LEDGER)
+
+	total, err := collection.CountDocuments(context.TODO(), bson.M{"manager": managerID})
+	if err != nil {
+		return entries, 0, err
+	}
+
+	cursor, err := collection.Find(context.TODO(), bson.M{"manager": managerID}, options.Find().
+		SetSort(bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page-1)*size)).
+		SetLimit(int64(size)))
+	if err != nil {
+		return entries, 0, err
+	}
+	err = cursor.All(context.TODO(), &entries)
+	if err != nil {
+		return entries, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// CheckLedger replays every manager's ledger and compares the running total
+// with the recorded balances and the manager's points. The ledger is append
+// only: points held before the ledger existed get an opening entry dated
+// before the first one, any drift from the manager's points gets a
+// correcting entry. Writes only happen when fix is true.
+func CheckLedger(fix bool) ([]string, error) {
+	issues := []string{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return issues, err
+	}
+	db := client.Database(constant.DB)
+
+	managers, err := GetManagers()
+	if err != nil {
+		return issues, err
+	}
+
+	for _, manager := range managers {
+		entries := []structs.LedgerEntry{}
+		cursor, err := db.Collection(constant.LEDGER).Find(context.TODO(), bson.M{"manager": manager.ID.Hex()},
+			options.Find().SetSort(bson.D{{Key: "at", Value: 1}, {Key: "_id", Value: 1}}))
+		if err != nil {
+			return issues, err
+		}
+		if err = cursor.All(context.TODO(), &entries); err != nil {
+			return issues, err
+		}
+
+		// the first entry records what the manager had before it
+		opening, openedAt := manager.Points, time.Now()
+		if len(entries) > 0 {
+			opening, openedAt = entries[0].Balance-entries[0].Amount, entries[0].At.Add(-time.Millisecond)
+		}
+		if opening != 0 && (len(entries) == 0 || entries[0].Source != constant.LEDGEROPENING) {
+			issues = append(issues, fmt.Sprintf("manager %s had %d points before the ledger", manager.Name, opening))
+			if fix {
+				err = appendEntry(db, manager, opening, opening, constant.LEDGEROPENING, "balance before the ledger existed", openedAt)
+				if err != nil {
+					return issues, err
+				}
+			}
+		} else {
+			opening = 0
+		}
+
+		balance := opening
+		for _, entry := range entries {
+			balance += entry.Amount
+			if entry.Balance != balance {
+				issues = append(issues, fmt.Sprintf("manager %s ledger entry %s records balance %d, replay gives %d", manager.Name, entry.ID.Hex(), entry.Balance, balance))
+			}
+		}
+
+		if manager.Points != balance {
+			issues = append(issues, fmt.Sprintf("manager %s has %d points, ledger gives %d", manager.Name, manager.Points, balance))
+			if fix {
+				err = appendEntry(db, manager, manager.Points-balance, manager.Points, constant.LEDGERCORRECT, "ledger drifted from the points", time.Now())
+				if err != nil {
+					return issues, err
+				}
+			}
+		}
+	}
+
+	return issues, nil
+}
+func appendEntry(db *mongo.Database, manager structs.Manager, amount, balance int, source, reason string, at time.Time) error {
+	_, err := db.Collection(constant.LEDGER).InsertOne(context.TODO(), structs.LedgerEntry{
+		Manager: manager.ID.Hex(),
+		Amount:  amount,
+		Balance: balance,
+		Source:  source,
+		Reason:  reason,
+		At:      at,
+	})
+	return err
+}
+
+// ledger-end
@@ -0,0 +1,88 @@
+package helper
+
+import (
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+)
+
+// lifecycle-start
+type SeasonHook func(season *structs.Season) error
+
+// seasonHooks run in order when a season enters the keyed status, any error
+// aborts the transition before it is saved.
+var seasonHooks = map[string][]SeasonHook{
+	constant.STATUSFINISHED: {lockStanding},
+	constant.STATUSACTIVE:   {unlockStanding},
+}
+
+func OnSeasonStatus(status string, hook SeasonHook) {
+	seasonHooks[status] = append(seasonHooks[status], hook)
+}
+func CanTransition(from, to string) bool {
+	found, _ := IsExistInSlice(constant.SEASON_TRANSITIONS[from], to)
+	return found
+}
+func TransitionSeason(season *structs.Season, status string) error {
+	if !CanTransition(season.Status, status) {
+		return fmt.Errorf("season can't move from %s to %s", season.Status, status)
+	}
+
+	for _, hook := range seasonHooks[status] {
+		err := hook(season)
+		if err != nil {
+			return err
+		}
+	}
+
+	season.ChangeStatus(status)
+	return nil
+}
+
+// SeasonStatus maps seasons created before the lifecycle existed onto it.
+func SeasonStatus(status string, isActive bool) string {
+	if status != "" {
+		return status
+	}
+	if isActive {
+		return constant.STATUSACTIVE
+	}
+	return constant.STATUSFINISHED
+}
+
+func lockStanding(season *structs.Season) error {
+	results, err := GetSeasonResults(season.ID.Hex())
+	if err != nil {
+		return err
+	}
+
+	switch season.Type {
+	case constant.TOURNAMENT:
+		season.FinalGroups = GetGroupStandings(*season, results)
+	case constant.CUP:
+	default:
+		season.FinalStanding = GetStanding(results, SeasonRules(*season))
+	}
+
+	season.Champion = ""
+	if season.Bracket != nil && len(season.Bracket.Rounds) > 0 {
+		final := season.Bracket.Rounds[len(season.Bracket.Rounds)-1].Ties[0]
+		if final.Winner == final.Home {
+			season.Champion = final.HomeManager
+		} else if final.Winner == final.Away {
+			season.Champion = final.AwayManager
+		}
+	} else if len(season.FinalStanding) > 0 {
+		season.Champion = season.FinalStanding[0].Manager
+	}
+
+	return nil
+}
+func unlockStanding(season *structs.Season) error {
+	season.FinalStanding = nil
+	season.FinalGroups = nil
+	season.Champion = ""
+	return nil
+}
+
+// lifecycle-end
@@ -0,0 +1,341 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// market-start
+var (
+	ErrListingClosed = errors.New("auction is over")
+	ErrBidTooLow     = errors.New("bid should be above the current bid and the start price")
+	ErrAlreadyListed = errors.New("player is already on the transfer market")
+)
+
+func listingCollection() (*mongo.Collection, error) {
+	client, err := GetMongoClient()
+	if err != nil {
+		return nil, err
+	}
+	err = PrepareCollections()
+	if err != nil {
+		return nil, err
+	}
+	return client.Database(constant.DB).Collection(constant.LISTINGS), nil
+}
+
+// CreateListing puts one of the seller's players up for auction.
+func CreateListing(seller structs.Manager, player structs.Player, startPrice, buyNow, hours int) (structs.Listing, error) {
+	now := time.Now()
+	listing := structs.Listing{
+		ID:         primitive.NewObjectID(),
+		Seller:     seller.ID.Hex(),
+		SellerName: seller.Name,
+		League:     seller.League,
+		Player:     player,
+		StartPrice: startPrice,
+		BuyNow:     buyNow,
+		Bids:       []structs.Bid{},
+		Status:     constant.LISTINGOPEN,
+		Active:     seller.ID.Hex() + ":" + player.ID.Hex(),
+		EndsAt:     now.Add(time.Duration(hours) * time.Hour),
+		CreatedAt:  now,
+	}
+	collection, err := listingCollection()
+	if err != nil {
+		return listing, err
+	}
+
+	_, err = collection.InsertOne(context.TODO(), listing)
+	if mongo.IsDuplicateKeyError(err) {
+		return listing, ErrAlreadyListed
+	}
+	return listing, err
+}
+func GetListingByID(id string) (structs.Listing, error) {
+	listing := structs.Listing{}
+
+	listingID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return listing, err
+	}
+	result, err := GetSingleResultByID(listingID, constant.LISTINGS)
+	if err != nil {
+		return listing, err
+	}
+
+	err = result.Decode(&listing)
+	if err != nil {
+		return listing, err
+	}
+
+	return listing, nil
+}
+
+// GetListings lists the open auctions of a league, the ones ending first
+// on top.
+func GetListings(league string) ([]structs.Listing, error) {
+	listings := []structs.Listing{}
+	collection, err := listingCollection()
+	if err != nil {
+		return listings, err
+	}
+
+	filter := leagueFilter(league)
+	filter["status"] = constant.LISTINGOPEN
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "endsAt", Value: 1}}))
+	if err != nil {
+		return listings, err
+	}
+	if err = cursor.All(context.TODO(), &listings); err != nil {
+		return listings, err
+	}
+
+	return listings, nil
+}
+
+// ListedPlayers returns the ids of the manager's players on the market.
+func ListedPlayers(managerID string) (map[string]bool, error) {
+	listed := make(map[string]bool)
+	collection, err := listingCollection()
+	if err != nil {
+		return listed, err
+	}
+
+	ids, err := collection.Distinct(context.TODO(), "player._id", bson.M{"seller": managerID, "status": constant.LISTINGOPEN})
+	if err != nil {
+		return listed, err
+	}
+	for _, id := range ids {
+		if objectID, ok := id.(primitive.ObjectID); ok {
+			listed[objectID.Hex()] = true
+		}
+	}
+	return listed, nil
+}
+
+// PlaceBid takes the bid into escrow and refunds the bidder it beats in one
+// transaction. A bid reaching the buy now price buys the player right away.
+func PlaceBid(listing *structs.Listing, bidder structs.Manager, amount int) error {
+	if listing.BuyNow > 0 && amount >= listing.BuyNow {
+		amount = listing.BuyNow
+	}
+	if amount < listing.StartPrice || amount <= listing.HighBid {
+		return ErrBidTooLow
+	}
+	collection, err := listingCollection()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	bid := structs.Bid{Manager: bidder.ID.Hex(), Name: bidder.Name, Amount: amount, At: now}
+	previous, previousBid := listing.HighBidder, listing.HighBid
+	err = WithTransaction(func(ctx mongo.SessionContext) error {
+		result, err := collection.UpdateOne(ctx, bson.M{
+			"_id":     listing.ID,
+			"status":  constant.LISTINGOPEN,
+			"endsAt":  bson.M{"$gt": now},
+			"highBid": previousBidFilter(previousBid),
+		}, bson.M{
+			"$set":  bson.M{"highBid": amount, "highBidder": bid.Manager},
+			"$push": bson.M{"bids": bid},
+		})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrBidTooLow
+		}
+
+		_, err = ApplyPoints(ctx, bidder.ID, nil, nil, structs.LedgerEntry{
+			Amount: -amount,
+			Source: constant.LEDGERESCROW,
+			Reason: fmt.Sprintf("bid on %s", listing.Player.Name),
+			Actor:  bid.Manager,
+		})
+		if err != nil {
+			return err
+		}
+		if previous == "" {
+			return nil
+		}
+		return refund(ctx, previous, previousBid, fmt.Sprintf("outbid on %s", listing.Player.Name))
+	})
+	if err != nil {
+		return err
+	}
+
+	listing.HighBid = amount
+	listing.HighBidder = bid.Manager
+	listing.Bids = append(listing.Bids, bid)
+	if listing.BuyNow > 0 && amount == listing.BuyNow {
+		return SettleListing(listing)
+	}
+	return nil
+}
+
+// previousBidFilter matches the high bid the bidder saw, listings without
+// bids don't store one.
+func previousBidFilter(highBid int) interface{} {
+	if highBid == 0 {
+		return nil
+	}
+	return highBid
+}
+func refund(ctx mongo.SessionContext, managerID string, amount int, reason string) error {
+	id, err := primitive.ObjectIDFromHex(managerID)
+	if err != nil {
+		return err
+	}
+	_, err = ApplyPoints(ctx, id, nil, nil, structs.LedgerEntry{
+		Amount: amount,
+		Source: constant.LEDGERREFUND,
+		Reason: reason,
+	})
+	return err
+}
+
+// SettleListing closes an auction. The player moves from the seller to the
+// highest bidder and the escrowed points to the seller in one transaction.
+// When the seller doesn't own the player anymore the bid is refunded, an
+// auction without bids just expires.
+func SettleListing(listing *structs.Listing) error {
+	collection, err := listingCollection()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	open := bson.M{"_id": listing.ID, "status": constant.LISTINGOPEN}
+
+	if listing.HighBidder == "" {
+		return closeListing(context.TODO(), collection, open, constant.LISTINGEXPIRED, now, listing)
+	}
+
+	sellerID, err := primitive.ObjectIDFromHex(listing.Seller)
+	if err != nil {
+		return err
+	}
+	buyerID, err := primitive.ObjectIDFromHex(listing.HighBidder)
+	if err != nil {
+		return err
+	}
+	playerID := listing.Player.ID
+
+	err = WithTransaction(func(ctx mongo.SessionContext) error {
+		err := closeListing(ctx, collection, open, constant.LISTINGSOLD, now, listing)
+		if err != nil {
+			return err
+		}
+
+		_, err = ApplyPoints(ctx, sellerID, bson.M{"players._id": playerID},
+			bson.M{"$pull": bson.M{"players": bson.M{"_id": playerID}}},
+			structs.LedgerEntry{
+				Amount: listing.HighBid,
+				Source: constant.LEDGERTRANSFER,
+				Reason: fmt.Sprintf("sold %s", listing.Player.Name),
+				Actor:  listing.HighBidder,
+			})
+		if err != nil {
+			return err
+		}
+
+		_, err = ApplyPoints(ctx, buyerID, bson.M{"players._id": bson.M{"$ne": playerID}},
+			bson.M{"$push": bson.M{"players": listing.Player}})
+		if err != nil {
+			return err
+		}
+
+		players := []primitive.ObjectID{playerID}
+		err = ReleasePlayers(ctx, listing.League, listing.Seller, players)
+		if err != nil {
+			return err
+		}
+		return ClaimPlayers(ctx, listing.League, listing.HighBidder, players)
+	})
+	if err != mongo.ErrNoDocuments && err != ErrPlayerTaken {
+		return err
+	}
+
+	// the seller let go of the player meanwhile or the buyer already has or
+	// can't hold it
+	return WithTransaction(func(ctx mongo.SessionContext) error {
+		err := closeListing(ctx, collection, open, constant.LISTINGCANCELLED, now, listing)
+		if err != nil {
+			return err
+		}
+		return refund(ctx, listing.HighBidder, listing.HighBid, fmt.Sprintf("%s left the market", listing.Player.Name))
+	})
+}
+
+// CancelListing takes a player without bids off the market.
+func CancelListing(listing *structs.Listing) error {
+	collection, err := listingCollection()
+	if err != nil {
+		return err
+	}
+	return closeListing(context.TODO(), collection, bson.M{"_id": listing.ID, "status": constant.LISTINGOPEN, "highBidder": nil},
+		constant.LISTINGCANCELLED, time.Now(), listing)
+}
+func closeListing(ctx context.Context, collection *mongo.Collection, filter bson.M, status string, now time.Time, listing *structs.Listing) error {
+	result, err := collection.UpdateOne(ctx, filter, bson.M{
+		"$set":   bson.M{"status": status, "settledAt": now},
+		"$unset": bson.M{"active": ""},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrListingClosed
+	}
+	listing.Status = status
+	listing.SettledAt = &now
+	return nil
+}
+
+// RunMarket settles every auction which ended. It checks the market every
+// interval until the process exits.
+func RunMarket(interval time.Duration) {
+	for range time.Tick(interval) {
+		listings, err := endedListings()
+		if err != nil {
+			log.Println("market:", err)
+			continue
+		}
+		for i := range listings {
+			err = SettleListing(&listings[i])
+			if err != nil && err != ErrListingClosed {
+				log.Println("market:", listings[i].ID.Hex(), err)
+			}
+		}
+	}
+}
+func endedListings() ([]structs.Listing, error) {
+	listings := []structs.Listing{}
+	collection, err := listingCollection()
+	if err != nil {
+		return listings, err
+	}
+
+	cursor, err := collection.Find(context.TODO(), bson.M{"status": constant.LISTINGOPEN, "endsAt": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		return listings, err
+	}
+	if err = cursor.All(context.TODO(), &listings); err != nil {
+		return listings, err
+	}
+
+	return listings, nil
+}
+
+// market-end
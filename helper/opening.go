@@ -0,0 +1,152 @@
+package helper
+
+import (
+	"context"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// opening-start
+// OpenPack charges a pack through the ledger and stores its history record
+// and draw in the same transaction, update adds the pulled players to the
+// roster. The pulled players which aren't duplicates are claimed in the
+// league, when the roster got one of them meanwhile nothing changes and
+// mongo.ErrNoDocuments is returned.
+func OpenPack(managerID primitive.ObjectID, update bson.M, opening *structs.PackOpening, draw *structs.Draw, entries ...structs.LedgerEntry) (structs.Manager, error) {
+	manager := structs.Manager{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return manager, err
+	}
+	db := client.Database(constant.DB)
+	err = PrepareCollections()
+	if err != nil {
+		return manager, err
+	}
+
+	opening.ID = primitive.NewObjectID()
+	opening.Manager = managerID.Hex()
+	opening.At = time.Now()
+	fresh := freshPlayers(opening)
+	condition := bson.M{"players._id": bson.M{"$nin": fresh}}
+	err = WithTransaction(func(ctx mongo.SessionContext) error {
+		manager, err = ApplyPoints(ctx, managerID, condition, update, entries...)
+		if err != nil {
+			return err
+		}
+		err = ClaimPlayers(ctx, opening.League, opening.Manager, fresh)
+		if err != nil {
+			return err
+		}
+		err = insertDraw(ctx, draw)
+		if err != nil {
+			return err
+		}
+		opening.Draw = draw.ID
+		opening.ServerSeed = draw.ServerSeed
+		opening.Nonce = draw.Nonce
+		_, err = db.Collection(constant.PACKOPENINGS).InsertOne(ctx, opening)
+		return err
+	})
+	return manager, err
+}
+
+func freshPlayers(opening *structs.PackOpening) []primitive.ObjectID {
+	duplicate := make(map[primitive.ObjectID]bool)
+	for _, player := range opening.Duplicates {
+		duplicate[player.ID] = true
+	}
+	fresh := []primitive.ObjectID{}
+	for _, player := range opening.Players {
+		if !duplicate[player.ID] {
+			fresh = append(fresh, player.ID)
+		}
+	}
+	return fresh
+}
+
+// GetPackOpenings returns one page of a manager's pack history, newest first,
+// and the total number of openings.
+func GetPackOpenings(managerID string, page, size int) ([]structs.PackOpening, int64, error) {
+	openings := []structs.PackOpening{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return openings, 0, err
+	}
+	collection := client.Database(constant.DB).Collection(constant.PACKOPENINGS)
+
+	total, err := collection.CountDocuments(context.TODO(), bson.M{"manager": managerID})
+	if err != nil {
+		return openings, 0, err
+	}
+
+	cursor, err := collection.Find(context.TODO(), bson.M{"manager": managerID}, options.Find().
+		SetSort(bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page-1)*size)).
+		SetLimit(int64(size)))
+	if err != nil {
+		return openings, 0, err
+	}
+	if err = cursor.All(context.TODO(), &openings); err != nil {
+		return openings, 0, err
+	}
+
+	return openings, total, nil
+}
+
+// GetLeaguePackStats ranks the best players pulled in a league since the given
+// time and sums up the openings of every manager, in one aggregation.
+func GetLeaguePackStats(league string, since time.Time) ([]structs.PackPull, []structs.ManagerPackStats, error) {
+	stats := []struct {
+		Pulls    []structs.PackPull         `bson:"pulls"`
+		Managers []structs.ManagerPackStats `bson:"managers"`
+	}{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	match := leagueFilter(league)
+	match["at"] = bson.M{"$gte": since}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$facet", Value: bson.M{
+			"pulls": bson.A{
+				bson.M{"$unwind": "$players"},
+				bson.M{"$sort": bson.D{{Key: "players.overall", Value: -1}, {Key: "at", Value: 1}}},
+				bson.M{"$limit": constant.PACKSTATSPULLS},
+				bson.M{"$project": bson.M{"manager": 1, "name": 1, "players": 1, "at": 1}},
+			},
+			"managers": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":         "$manager",
+					"opened":      bson.M{"$sum": 1},
+					"spent":       bson.M{"$sum": "$price"},
+					"quickSold":   bson.M{"$sum": "$quickSold"},
+					"bestOverall": bson.M{"$max": bson.M{"$max": "$players.overall"}},
+				}},
+				bson.M{"$sort": bson.D{{Key: "bestOverall", Value: -1}, {Key: "opened", Value: -1}}},
+			},
+		}}},
+	}
+	cursor, err := client.Database(constant.DB).Collection(constant.PACKOPENINGS).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cursor.All(context.TODO(), &stats); err != nil {
+		return nil, nil, err
+	}
+	if len(stats) == 0 {
+		return []structs.PackPull{}, []structs.ManagerPackStats{}, nil
+	}
+
+	return stats[0].Pulls, stats[0].Managers, nil
+}
+
+// opening-end
@@ -0,0 +1,324 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pack-start
+// defaultPacks are the tiers which used to be hard-coded, they are stored
+// when the pack collection is created so existing clients sending a pack type
+// keep working.
+var defaultPacks = []structs.PackDefinition{
+	defaultPack("silver", constant.SILVER, "Silver", 500, 65, 69),
+	defaultPack("premium-silver", constant.PREMIUMSILVER, "Premium Silver", 750, 70, 74),
+	defaultPack("gold", constant.GOLD, "Gold", 2000, 75, 79),
+	defaultPack("premium-gold", constant.PREMIUMGOLD, "Premium Gold", 5000, 77, 83),
+	defaultPack("ultimate-gold", constant.ULTIMATEGOLD, "Ultimate Gold", 10000, 81, 87),
+	defaultPack("prime-gold", constant.PRIMEGOLD, "Prime Gold", 15000, 84, 99),
+}
+
+func defaultPack(key string, packType int, name string, price, minOverall, maxOverall int) structs.PackDefinition {
+	return structs.PackDefinition{
+		Key:     key,
+		Type:    &packType,
+		Name:    name,
+		Price:   price,
+		Players: 1,
+		OnSale:  true,
+		Odds:    []structs.PackOdds{{Weight: 1, MinOverall: minOverall, MaxOverall: maxOverall}},
+	}
+}
+
+func packCollection() (*mongo.Collection, error) {
+	client, err := GetMongoClient()
+	if err != nil {
+		return nil, err
+	}
+	err = PrepareCollections()
+	if err != nil {
+		return nil, err
+	}
+	return client.Database(constant.DB).Collection(constant.PACKS), nil
+}
+
+// seedPacks stores the default packs, a pack another process stored first is
+// kept.
+func seedPacks(db *mongo.Database) error {
+	for _, pack := range defaultPacks {
+		pack.UpdatedAt = time.Now()
+		_, err := db.Collection(constant.PACKS).UpdateOne(context.TODO(), bson.M{"key": pack.Key},
+			bson.M{"$setOnInsert": pack}, options.Update().SetUpsert(true))
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPacks lists pack definitions sorted by price, onSale limits them to the
+// packs managers can buy.
+func GetPacks(onSale bool) ([]structs.PackDefinition, error) {
+	packs := []structs.PackDefinition{}
+	collection, err := packCollection()
+	if err != nil {
+		return packs, err
+	}
+
+	filter := bson.M{}
+	if onSale {
+		filter["onSale"] = true
+	}
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "price", Value: 1}}))
+	if err != nil {
+		return packs, err
+	}
+	if err = cursor.All(context.TODO(), &packs); err != nil {
+		return packs, err
+	}
+	for i := range packs {
+		PublishOdds(&packs[i])
+	}
+
+	return packs, nil
+}
+
+// GetPack finds a pack by key, clients which only know the old numbered
+// tiers can still pass the type instead.
+func GetPack(key string, packType int) (structs.PackDefinition, error) {
+	pack := structs.PackDefinition{}
+	collection, err := packCollection()
+	if err != nil {
+		return pack, err
+	}
+
+	filter := bson.M{"key": key}
+	if key == "" {
+		filter = bson.M{"type": packType}
+	}
+	err = collection.FindOne(context.TODO(), filter).Decode(&pack)
+	if err != nil {
+		return pack, err
+	}
+	PublishOdds(&pack)
+
+	return pack, nil
+}
+func SavePack(pack structs.PackDefinition) (structs.PackDefinition, error) {
+	collection, err := packCollection()
+	if err != nil {
+		return pack, err
+	}
+
+	pack.UpdatedAt = time.Now()
+	err = collection.FindOneAndReplace(context.TODO(), bson.M{"key": pack.Key}, pack,
+		options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.After)).Decode(&pack)
+	if err != nil {
+		return pack, err
+	}
+	PublishOdds(&pack)
+
+	return pack, nil
+}
+func DeletePack(key string) (int64, error) {
+	collection, err := packCollection()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := collection.DeleteOne(context.TODO(), bson.M{"key": key})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+func ValidatePack(pack structs.PackDefinition) error {
+	if pack.Key == "" || pack.Name == "" {
+		return fmt.Errorf("pack needs a key and a name")
+	}
+	if pack.Price < 0 {
+		return fmt.Errorf("pack price can't be negative")
+	}
+	if pack.Players < 1 {
+		return fmt.Errorf("pack should contain at least one player")
+	}
+	err := validateOdds(pack.Odds)
+	if err != nil {
+		return err
+	}
+
+	guaranteed := 0
+	for _, slot := range pack.Guarantees {
+		if slot.Count < 1 {
+			return fmt.Errorf("guaranteed slot %s should hold at least one player", slot.Label)
+		}
+		err = validateOdds(slot.Odds)
+		if err != nil {
+			return fmt.Errorf("guaranteed slot %s: %s", slot.Label, err)
+		}
+		guaranteed += slot.Count
+	}
+	if guaranteed > pack.Players {
+		return fmt.Errorf("pack guarantees %d players but only holds %d", guaranteed, pack.Players)
+	}
+	return nil
+}
+func validateOdds(table []structs.PackOdds) error {
+	if len(table) == 0 {
+		return fmt.Errorf("pack needs an odds table")
+	}
+	for _, odds := range table {
+		if odds.Weight < 1 {
+			return fmt.Errorf("odds weights should be positive")
+		}
+		if odds.MinOverall > odds.MaxOverall {
+			return fmt.Errorf("odds band %d-%d is empty", odds.MinOverall, odds.MaxOverall)
+		}
+		if odds.Rarity != "" {
+			found, _ := IsExistInSlice(constant.RARITIES, odds.Rarity)
+			if !found {
+				return fmt.Errorf("unknown rarity %s", odds.Rarity)
+			}
+		}
+	}
+	return nil
+}
+
+// PublishOdds fills in the chance of every odds row in percent.
+func PublishOdds(pack *structs.PackDefinition) {
+	publishTable(pack.Odds)
+	for _, slot := range pack.Guarantees {
+		publishTable(slot.Odds)
+	}
+}
+func publishTable(table []structs.PackOdds) {
+	total := 0
+	for _, odds := range table {
+		total += odds.Weight
+	}
+	for i := range table {
+		if total > 0 {
+			table[i].Chance = float64(table[i].Weight) * 100 / float64(total)
+		}
+	}
+}
+
+// PickOdds chooses a row of the odds table by weight and returns the
+// summed weight and the roll it was chosen with.
+func PickOdds(odds []structs.PackOdds, rng *DrawRNG) (structs.PackOdds, int, int) {
+	total := 0
+	for _, row := range odds {
+		total += row.Weight
+	}
+	roll := rng.Intn(total)
+	pick := roll
+	for _, row := range odds {
+		if pick < row.Weight {
+			return row, total, roll
+		}
+		pick -= row.Weight
+	}
+	return odds[len(odds)-1], total, roll
+}
+
+// OddsFilter builds the player query of one odds row within a pack.
+// Excluded players, like the ones already in the pack, are left out.
+func OddsFilter(odds structs.PackOdds, filter *structs.PackFilter, excluded []primitive.ObjectID) bson.M {
+	conditions := bson.A{
+		bson.M{"hidden": nil},
+		bson.M{"overall": bson.M{"$gte": odds.MinOverall, "$lte": odds.MaxOverall}},
+	}
+	if len(excluded) > 0 {
+		conditions = append(conditions, bson.M{"_id": bson.M{"$nin": excluded}})
+	}
+	if odds.Rarity != "" {
+		conditions = append(conditions, bson.M{"rarity": odds.Rarity})
+	}
+	if len(odds.Leagues) > 0 {
+		conditions = append(conditions, bson.M{"league_name": bson.M{"$in": odds.Leagues}})
+	}
+	if filter != nil {
+		if len(filter.Nations) > 0 {
+			conditions = append(conditions, bson.M{"nationality_name": bson.M{"$in": filter.Nations}})
+		}
+		if len(filter.Leagues) > 0 {
+			conditions = append(conditions, bson.M{"league_name": bson.M{"$in": filter.Leagues}})
+		}
+		if len(filter.Clubs) > 0 {
+			conditions = append(conditions, bson.M{"club_name": bson.M{"$in": filter.Clubs}})
+		}
+	}
+	return bson.M{"$and": conditions}
+}
+
+// DrawPack fills the guaranteed slots first and the rest of the pack from the
+// pack odds, never drawing the same player twice nor a taken one. Every pick
+// is recorded on the draw so it can be replayed.
+func DrawPack(pack structs.PackDefinition, taken []primitive.ObjectID, draw *structs.Draw, rng *DrawRNG) ([]structs.Player, error) {
+	players := []structs.Player{}
+	excluded := append([]primitive.ObjectID{}, taken...)
+	for _, table := range packTables(pack) {
+		odds, total, roll := PickOdds(table, rng)
+		player, pick, err := SamplePlayer(odds, pack.Filter, excluded, rng)
+		if err != nil {
+			return players, fmt.Errorf("%s: %s", pack.Name, err)
+		}
+		pick.Total = total
+		pick.Roll = roll
+		players = append(players, player)
+		excluded = append(excluded, player.ID)
+		draw.Picks = append(draw.Picks, pick)
+	}
+	return players, nil
+}
+
+// packTables lists the odds table of every slot of the pack.
+func packTables(pack structs.PackDefinition) [][]structs.PackOdds {
+	tables := [][]structs.PackOdds{}
+	for _, slot := range pack.Guarantees {
+		for i := 0; i < slot.Count; i++ {
+			tables = append(tables, slot.Odds)
+		}
+	}
+	for len(tables) < pack.Players {
+		tables = append(tables, pack.Odds)
+	}
+	return tables
+}
+
+// candidateIDs lists the ids of the matching players in a stable order, so
+// the same roll always lands on the same player.
+func candidateIDs(filter bson.M) ([]primitive.ObjectID, error) {
+	ids := []primitive.ObjectID{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return ids, err
+	}
+
+	documents := []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}{}
+	cursor, err := client.Database(constant.DB).Collection(constant.PLAYERS).Find(context.TODO(), filter,
+		options.Find().SetProjection(bson.M{"_id": 1}).SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return ids, err
+	}
+	if err = cursor.All(context.TODO(), &documents); err != nil {
+		return ids, err
+	}
+	for _, document := range documents {
+		ids = append(ids, document.ID)
+	}
+
+	return ids, nil
+}
+
+// pack-end
@@ -0,0 +1,39 @@
+package helper
+
+import (
+	"manager-sensin/structs"
+	"testing"
+)
+
+func TestPickOdds(t *testing.T) {
+	odds := []structs.PackOdds{
+		{Label: "bronze", Weight: 70},
+		{Label: "silver", Weight: 25},
+		{Label: "gold", Weight: 5},
+	}
+	tests := []struct {
+		name  string
+		odds  []structs.PackOdds
+		nonce int
+		label string
+		total int
+		roll  int
+	}{
+		{"lowest roll", odds, 15, "bronze", 100, 0},
+		{"last bronze roll", odds, 149, "bronze", 100, 69},
+		{"first silver roll", odds, 121, "silver", 100, 70},
+		{"last silver roll", odds, 72, "silver", 100, 94},
+		{"first gold roll", odds, 127, "gold", 100, 95},
+		{"highest roll", odds, 90, "gold", 100, 99},
+		{"single row", []structs.PackOdds{{Label: "only", Weight: 1}}, 0, "only", 1, 0},
+		{"zero weight row is never picked", []structs.PackOdds{{Label: "never", Weight: 0}, {Label: "always", Weight: 3}}, 0, "always", 3, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			row, total, roll := PickOdds(test.odds, NewDrawRNG("server", "client", test.nonce))
+			if row.Label != test.label || total != test.total || roll != test.roll {
+				t.Errorf("PickOdds = %s, %d, %d, want %s, %d, %d", row.Label, total, roll, test.label, test.total, test.roll)
+			}
+		})
+	}
+}
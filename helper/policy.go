@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"net/http"
+)
+
+// policy-start
+// ManagerRole treats managers created before roles existed as plain managers.
+func ManagerRole(manager structs.Manager) string {
+	if manager.Role == "" {
+		return constant.ROLEMANAGER
+	}
+	return manager.Role
+}
+func HasRole(manager structs.Manager, roles ...string) bool {
+	found, _ := IsExistInSlice(roles, ManagerRole(manager))
+	return found
+}
+
+// AuthorizeRoles allows the caller when they hold one of the roles.
+func AuthorizeRoles(caller structs.Manager, roles ...string) error {
+	if HasRole(caller, roles...) {
+		return nil
+	}
+	return fmt.Errorf("%s role can't access this endpoint", ManagerRole(caller))
+}
+
+// AuthorizeSelf allows admins and the manager the request acts for.
+func AuthorizeSelf(caller structs.Manager, managerID string) error {
+	if HasRole(caller, constant.ROLEADMIN) || caller.ID.Hex() == managerID {
+		return nil
+	}
+	return fmt.Errorf("managers can only act for themselves")
+}
+
+// AuthorizeSeason allows admins and the league owner who created the season.
+func AuthorizeSeason(caller structs.Manager, season structs.Season) error {
+	if HasRole(caller, constant.ROLEADMIN) {
+		return nil
+	}
+	if HasRole(caller, constant.ROLEOWNER) && season.Owner == caller.ID.Hex() {
+		return nil
+	}
+	return fmt.Errorf("only the owner of %s can manage it", season.Title)
+}
+
+func ReturnForbidden(w http.ResponseWriter, err error) {
+	ReturnError(w, http.StatusForbidden, err, constant.FORBIDDENERROR)
+}
+
+// policy-end
@@ -0,0 +1,140 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// quicksell-start
+const quickSellSetting = "quicksell"
+
+var defaultQuickSell = structs.QuickSell{
+	ID:         quickSellSetting,
+	Duplicates: true,
+	Bands: []structs.QuickSellBand{
+		{MinOverall: 0, Value: 50},
+		{MinOverall: 65, Value: 100},
+		{MinOverall: 70, Value: 200},
+		{MinOverall: 75, Value: 400},
+		{MinOverall: 80, Value: 800},
+		{MinOverall: 85, Value: 1500},
+		{MinOverall: 90, Value: 3000},
+	},
+}
+
+// GetQuickSell returns the stored quick sell prices or the defaults when an
+// admin never changed them.
+func GetQuickSell() (structs.QuickSell, error) {
+	config := structs.QuickSell{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return config, err
+	}
+
+	err = client.Database(constant.DB).Collection(constant.SETTINGS).FindOne(context.TODO(), bson.M{"_id": quickSellSetting}).Decode(&config)
+	if err == mongo.ErrNoDocuments {
+		return defaultQuickSell, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+func SaveQuickSell(config structs.QuickSell) (structs.QuickSell, error) {
+	client, err := GetMongoClient()
+	if err != nil {
+		return config, err
+	}
+	db := client.Database(constant.DB)
+	err = CreateCollection(db, constant.SETTINGS)
+	if err != nil {
+		return config, err
+	}
+
+	config.ID = quickSellSetting
+	sort.Slice(config.Bands, func(i, j int) bool {
+		return config.Bands[i].MinOverall < config.Bands[j].MinOverall
+	})
+	_, err = db.Collection(constant.SETTINGS).ReplaceOne(context.TODO(), bson.M{"_id": quickSellSetting}, config,
+		options.Replace().SetUpsert(true))
+	if err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+func ValidateQuickSell(config structs.QuickSell) error {
+	if len(config.Bands) == 0 {
+		return fmt.Errorf("quick sell needs at least one band")
+	}
+	for _, band := range config.Bands {
+		if band.Value < 0 {
+			return fmt.Errorf("quick sell values can't be negative")
+		}
+		if band.Rarity != "" {
+			found, _ := IsExistInSlice(constant.RARITIES, band.Rarity)
+			if !found {
+				return fmt.Errorf("unknown rarity %s", band.Rarity)
+			}
+		}
+	}
+	return nil
+}
+
+// QuickSellValue prices a player with the highest band its overall reaches
+// among the bands for its rarity and the ones for every rarity. When both
+// start at the same overall the band for its rarity wins.
+func QuickSellValue(config structs.QuickSell, player structs.Player) int {
+	value := 0
+	reached := -1
+	specific := false
+	for _, band := range config.Bands {
+		if band.Rarity != "" && band.Rarity != player.Rarity {
+			continue
+		}
+		if player.Overall < band.MinOverall {
+			continue
+		}
+		if band.MinOverall > reached || (band.MinOverall == reached && band.Rarity != "" && !specific) {
+			value = band.Value
+			reached = band.MinOverall
+			specific = band.Rarity != ""
+		}
+	}
+	return value
+}
+
+// QuickSellPlayers pays the entry for the players, pulls them from the
+// roster and releases their claims in one transaction. The players must still
+// be owned when it runs, a concurrent sale or trade makes the whole quick sell
+// fail with mongo.ErrNoDocuments instead of paying twice.
+func QuickSellPlayers(manager structs.Manager, ids []primitive.ObjectID, entry structs.LedgerEntry) (structs.Manager, error) {
+	sold := structs.Manager{}
+	err := PrepareCollections()
+	if err != nil {
+		return sold, err
+	}
+
+	err = WithTransaction(func(ctx mongo.SessionContext) error {
+		sold, err = ApplyPoints(ctx, manager.ID,
+			bson.M{"players._id": bson.M{"$all": ids}},
+			bson.M{"$pull": bson.M{"players": bson.M{"_id": bson.M{"$in": ids}}}},
+			entry)
+		if err != nil {
+			return err
+		}
+		return ReleasePlayers(ctx, manager.League, manager.ID.Hex(), ids)
+	})
+	return sold, err
+}
+
+// quicksell-end
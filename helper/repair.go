@@ -0,0 +1,168 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// repair-start
+// RepairResults finds fixtures which disagree with the results collection:
+// fixtures pointing to a result that no longer exists and results whose
+// fixture was never marked as played. The problems are always reported and
+// only written back when fix is true.
+func RepairResults(fix bool) ([]string, error) {
+	issues := []string{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return issues, err
+	}
+	db := client.Database(constant.DB)
+
+	results := []structs.Result{}
+	cursor, err := db.Collection(constant.RESULTS).Find(context.TODO(), bson.M{})
+	if err != nil {
+		return issues, err
+	}
+	if err = cursor.All(context.TODO(), &results); err != nil {
+		return issues, err
+	}
+
+	seasons := []structs.Season{}
+	cursor, err = db.Collection(constant.SEASONS).Find(context.TODO(), bson.M{})
+	if err != nil {
+		return issues, err
+	}
+	if err = cursor.All(context.TODO(), &seasons); err != nil {
+		return issues, err
+	}
+
+	stored := make(map[string]bool)
+	seasonResults := make(map[string][]structs.Result)
+	for _, result := range results {
+		stored[result.ID.Hex()] = true
+		seasonResults[result.Season] = append(seasonResults[result.Season], result)
+	}
+	seasonByID := make(map[string]*structs.Season)
+	for i := range seasons {
+		seasonByID[seasons[i].ID.Hex()] = &seasons[i]
+	}
+	changedSeasons := make(map[string]bool)
+
+	for _, season := range seasonByID {
+		for i, fixture := range season.Fixtures {
+			if fixture.Result != "" && !stored[fixture.Result] {
+				issues = append(issues, fmt.Sprintf("season %s fixture %s points to missing result %s", season.Title, fixture.ID.Hex(), fixture.Result))
+				season.UnplayFixture(i)
+				changedSeasons[season.ID.Hex()] = true
+			}
+		}
+	}
+
+	for _, result := range results {
+		season, found := seasonByID[result.Season]
+		if !found {
+			issues = append(issues, fmt.Sprintf("result %s references unknown season %s", result.ID.Hex(), result.Season))
+			continue
+		}
+		fixtureFound, fixtureIndex := season.FixtureByID(result.Fixture)
+		if fixtureFound && season.Fixtures[fixtureIndex].Result == "" {
+			issues = append(issues, fmt.Sprintf("result %s is missing on fixture %s", result.ID.Hex(), result.Fixture))
+			season.PlayFixture(fixtureIndex, result)
+			AdvanceBracket(season, fixtureIndex)
+			_, err = CompleteGroupStage(season, seasonResults[result.Season])
+			if err != nil {
+				return issues, err
+			}
+			changedSeasons[result.Season] = true
+		}
+	}
+
+	if !fix {
+		return issues, nil
+	}
+
+	for id := range changedSeasons {
+		_, err = UpdateSeason(seasonByID[id])
+		if err != nil {
+			return issues, err
+		}
+	}
+
+	return issues, nil
+}
+
+// MigrateEmbeddedResults moves the result copies managers and seasons used to
+// embed into the results collection. Results are keyed by their original id,
+// so copies of the same result collapse into one document and running the
+// migration again is harmless. The embedded arrays are dropped afterwards.
+func MigrateEmbeddedResults() (int, error) {
+	moved := 0
+	client, err := GetMongoClient()
+	if err != nil {
+		return moved, err
+	}
+	db := client.Database(constant.DB)
+	err = CreateCollection(db, constant.RESULTS)
+	if err != nil {
+		return moved, err
+	}
+
+	for _, collection := range []string{constant.SEASONS, constant.MANAGERS} {
+		documents := []struct {
+			ID      primitive.ObjectID `bson:"_id"`
+			Results []structs.Result   `bson:"results"`
+		}{}
+		cursor, err := db.Collection(collection).Find(context.TODO(), bson.M{"results": bson.M{"$exists": true}},
+			options.Find().SetProjection(bson.M{"results": 1}))
+		if err != nil {
+			return moved, err
+		}
+		if err = cursor.All(context.TODO(), &documents); err != nil {
+			return moved, err
+		}
+
+		for _, document := range documents {
+			for _, result := range document.Results {
+				id := result.ID
+				if id.IsZero() {
+					id = primitive.NewObjectID()
+				}
+				result.ID = primitive.NilObjectID
+				update, err := db.Collection(constant.RESULTS).UpdateOne(context.TODO(), bson.M{"_id": id},
+					bson.M{"$setOnInsert": result}, options.Update().SetUpsert(true))
+				if err != nil {
+					return moved, err
+				}
+				if update.UpsertedCount > 0 {
+					moved++
+				}
+			}
+		}
+
+		_, err = db.Collection(collection).UpdateMany(context.TODO(), bson.M{"results": bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{"results": ""}})
+		if err != nil {
+			return moved, err
+		}
+	}
+
+	_, err = db.Collection(constant.RESULTS).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "season", Value: 1}, {Key: "matchday", Value: 1}}},
+		{Keys: bson.D{{Key: "home", Value: 1}}},
+		{Keys: bson.D{{Key: "away", Value: 1}}},
+	})
+	if err != nil {
+		return moved, err
+	}
+
+	return moved, nil
+}
+
+// repair-end
@@ -0,0 +1,350 @@
+package helper
+
+import (
+	"context"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// rng-start
+// DrawRNG is a deterministic random stream for one draw. Every 32 byte block
+// is HMAC-SHA256(server seed, "clientSeed:nonce:block"), so anybody who knows
+// the revealed server seed can replay the draw.
+type DrawRNG struct {
+	key     []byte
+	message string
+	block   int
+	buffer  []byte
+}
+
+func NewDrawRNG(serverSeed, clientSeed string, nonce int) *DrawRNG {
+	return &DrawRNG{key: []byte(serverSeed), message: fmt.Sprintf("%s:%d", clientSeed, nonce)}
+}
+func (r *DrawRNG) Uint64() uint64 {
+	if len(r.buffer) < 8 {
+		mac := hmac.New(sha256.New, r.key)
+		mac.Write([]byte(fmt.Sprintf("%s:%d", r.message, r.block)))
+		r.block++
+		r.buffer = append(r.buffer, mac.Sum(nil)...)
+	}
+	value := binary.BigEndian.Uint64(r.buffer[:8])
+	r.buffer = r.buffer[8:]
+	return value
+}
+
+// Intn returns a number in [0, n). Values from the uneven tail of the uint64
+// range are thrown away so every result is equally likely.
+func (r *DrawRNG) Intn(n int) int {
+	limit := math.MaxUint64 - math.MaxUint64%uint64(n)
+	for {
+		value := r.Uint64()
+		if value < limit {
+			return int(value % uint64(n))
+		}
+	}
+}
+
+// shared backs GetRandom for shuffles which don't need to be replayed. It is
+// seeded once instead of on every call.
+var shared = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(cryptoSeed()))}
+
+func cryptoSeed() int64 {
+	seed := make([]byte, 8)
+	_, err := crand.Read(seed)
+	if err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.BigEndian.Uint64(seed))
+}
+
+func HashSeed(seed string) string {
+	hash := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(hash[:])
+}
+
+// HashPool fingerprints a candidate pool so a replay can tell whether it drew
+// from the same players.
+func HashPool(ids []primitive.ObjectID) string {
+	hash := sha256.New()
+	for _, id := range ids {
+		hash.Write(id[:])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// PublishSeed hides the seed until it has been revealed.
+func PublishSeed(seed structs.ServerSeed) structs.ServerSeed {
+	if seed.RevealedAt == nil {
+		seed.Seed = ""
+	}
+	return seed
+}
+
+// CurrentSeed returns the commitment new draws are made with, the first one
+// is created on demand.
+func CurrentSeed() (structs.ServerSeed, error) {
+	seed := structs.ServerSeed{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return seed, err
+	}
+	collection := client.Database(constant.DB).Collection(constant.SEEDS)
+
+	err = collection.FindOne(context.TODO(), bson.M{"revealedAt": nil},
+		options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})).Decode(&seed)
+	if err == mongo.ErrNoDocuments {
+		return newSeed()
+	}
+	return seed, err
+}
+func newSeed() (structs.ServerSeed, error) {
+	secret := make([]byte, 32)
+	_, err := crand.Read(secret)
+	if err != nil {
+		return structs.ServerSeed{}, err
+	}
+	seed := structs.ServerSeed{
+		ID:        primitive.NewObjectID(),
+		Seed:      hex.EncodeToString(secret),
+		CreatedAt: time.Now(),
+	}
+	seed.Hash = HashSeed(seed.Seed)
+
+	client, err := GetMongoClient()
+	if err != nil {
+		return seed, err
+	}
+	_, err = client.Database(constant.DB).Collection(constant.SEEDS).InsertOne(context.TODO(), seed)
+	return seed, err
+}
+
+// RotateSeed reveals the seeds in use, which makes their draws verifiable,
+// and commits to a new one.
+func RotateSeed() ([]structs.ServerSeed, structs.ServerSeed, error) {
+	revealed := []structs.ServerSeed{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return revealed, structs.ServerSeed{}, err
+	}
+	collection := client.Database(constant.DB).Collection(constant.SEEDS)
+
+	cursor, err := collection.Find(context.TODO(), bson.M{"revealedAt": nil})
+	if err != nil {
+		return revealed, structs.ServerSeed{}, err
+	}
+	if err = cursor.All(context.TODO(), &revealed); err != nil {
+		return revealed, structs.ServerSeed{}, err
+	}
+
+	next, err := newSeed()
+	if err != nil {
+		return revealed, next, err
+	}
+
+	now := time.Now()
+	ids := bson.A{}
+	for i := range revealed {
+		revealed[i].RevealedAt = &now
+		ids = append(ids, revealed[i].ID)
+	}
+	_, err = collection.UpdateMany(context.TODO(), bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$set": bson.M{"revealedAt": now}})
+	if err != nil {
+		return revealed, next, err
+	}
+
+	return revealed, next, nil
+}
+
+// NextDraw reserves a nonce of the current seed for a new draw. An empty
+// client seed is replaced by a random one.
+func NextDraw(kind, clientSeed string) (structs.Draw, *DrawRNG, error) {
+	draw := structs.Draw{Kind: kind, ClientSeed: clientSeed, Picks: []structs.DrawPick{}}
+	if draw.ClientSeed == "" {
+		nonce := make([]byte, 16)
+		_, err := crand.Read(nonce)
+		if err != nil {
+			return draw, nil, err
+		}
+		draw.ClientSeed = hex.EncodeToString(nonce)
+	}
+
+	current, err := CurrentSeed()
+	if err != nil {
+		return draw, nil, err
+	}
+	client, err := GetMongoClient()
+	if err != nil {
+		return draw, nil, err
+	}
+
+	seed := structs.ServerSeed{}
+	err = client.Database(constant.DB).Collection(constant.SEEDS).FindOneAndUpdate(context.TODO(),
+		bson.M{"_id": current.ID, "revealedAt": nil}, bson.M{"$inc": bson.M{"nonce": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&seed)
+	if err != nil {
+		return draw, nil, fmt.Errorf("server seed was rotated, try again")
+	}
+
+	draw.ServerSeed = seed.ID
+	draw.Nonce = seed.Nonce
+	return draw, NewDrawRNG(seed.Seed, draw.ClientSeed, draw.Nonce), nil
+}
+func SaveDraw(draw *structs.Draw) error {
+	return insertDraw(context.TODO(), draw)
+}
+func insertDraw(ctx context.Context, draw *structs.Draw) error {
+	client, err := GetMongoClient()
+	if err != nil {
+		return err
+	}
+
+	draw.ID = primitive.NewObjectID()
+	draw.At = time.Now()
+	_, err = client.Database(constant.DB).Collection(constant.DRAWS).InsertOne(ctx, draw)
+	return err
+}
+
+// SavePool stores a candidate list under its hash, once per distinct list,
+// so draws taken from it can be verified after the players changed.
+func SavePool(ids []primitive.ObjectID) (string, error) {
+	hash := HashPool(ids)
+	client, err := GetMongoClient()
+	if err != nil {
+		return hash, err
+	}
+
+	_, err = client.Database(constant.DB).Collection(constant.POOLS).UpdateOne(context.TODO(), bson.M{"_id": hash},
+		bson.M{"$setOnInsert": bson.M{"ids": ids, "at": time.Now()}}, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return hash, nil
+	}
+	return hash, err
+}
+func loadPools(picks []structs.DrawPick) (map[string][]primitive.ObjectID, error) {
+	pools := make(map[string][]primitive.ObjectID)
+	hashes := bson.A{}
+	for _, pick := range picks {
+		hashes = append(hashes, pick.PoolHash)
+	}
+	client, err := GetMongoClient()
+	if err != nil {
+		return pools, err
+	}
+
+	documents := []struct {
+		Hash string               `bson:"_id"`
+		IDs  []primitive.ObjectID `bson:"ids"`
+	}{}
+	cursor, err := client.Database(constant.DB).Collection(constant.POOLS).Find(context.TODO(), bson.M{"_id": bson.M{"$in": hashes}})
+	if err != nil {
+		return pools, err
+	}
+	if err = cursor.All(context.TODO(), &documents); err != nil {
+		return pools, err
+	}
+	for _, document := range documents {
+		pools[document.Hash] = document.IDs
+	}
+	return pools, nil
+}
+
+// VerifyDraw replays a stored draw with its server seed and tells whether
+// every roll and pick comes out the same.
+func VerifyDraw(id string) (structs.Draw, structs.ServerSeed, bool, error) {
+	draw := structs.Draw{}
+	seed := structs.ServerSeed{}
+
+	drawID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return draw, seed, false, err
+	}
+	result, err := GetSingleResultByID(drawID, constant.DRAWS)
+	if err != nil {
+		return draw, seed, false, err
+	}
+	if err = result.Decode(&draw); err != nil {
+		return draw, seed, false, err
+	}
+	result, err = GetSingleResultByID(draw.ServerSeed, constant.SEEDS)
+	if err != nil {
+		return draw, seed, false, err
+	}
+	if err = result.Decode(&seed); err != nil {
+		return draw, seed, false, err
+	}
+	pools, err := loadPools(draw.Picks)
+	if err != nil {
+		return draw, seed, false, err
+	}
+
+	return draw, seed, ReplayDraw(draw, seed, pools), nil
+}
+
+// ReplayDraw checks a draw against its server seed and the stored candidate
+// pools: every roll has to come out the same and every pick has to be the
+// candidate its index points to.
+func ReplayDraw(draw structs.Draw, seed structs.ServerSeed, pools map[string][]primitive.ObjectID) bool {
+	if HashSeed(seed.Seed) != seed.Hash || draw.Nonce > seed.Nonce {
+		return false
+	}
+	rng := NewDrawRNG(seed.Seed, draw.ClientSeed, draw.Nonce)
+	for _, pick := range draw.Picks {
+		if pick.Total > 0 && rng.Intn(pick.Total) != pick.Roll {
+			return false
+		}
+		if pick.Pool < 1 || rng.Intn(pick.Pool) != pick.Index {
+			return false
+		}
+
+		ids, found := pools[pick.PoolHash]
+		if !found || HashPool(ids) != pick.PoolHash {
+			return false
+		}
+		remaining, ok := withoutSkipped(ids, pick.Skipped)
+		if !ok || len(remaining) != pick.Pool || remaining[pick.Index] != pick.Player {
+			return false
+		}
+	}
+	return true
+}
+
+// withoutSkipped leaves the skipped ids out of the pool, every one of them
+// has to be a candidate.
+func withoutSkipped(ids, skipped []primitive.ObjectID) ([]primitive.ObjectID, bool) {
+	left := make(map[primitive.ObjectID]bool)
+	for _, id := range skipped {
+		if left[id] {
+			return nil, false
+		}
+		left[id] = true
+	}
+	remaining := []primitive.ObjectID{}
+	for _, id := range ids {
+		if left[id] {
+			delete(left, id)
+			continue
+		}
+		remaining = append(remaining, id)
+	}
+	return remaining, len(left) == 0
+}
+
+// rng-end
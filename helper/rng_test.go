@@ -0,0 +1,169 @@
+package helper
+
+import (
+	"manager-sensin/structs"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDrawRNGIntn(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverSeed string
+		clientSeed string
+		nonce      int
+		n          int
+		want       []int
+	}{
+		{"ten", "server", "client", 0, 10, []int{4, 7, 7, 0, 9}},
+		{"next nonce", "server", "client", 1, 10, []int{6, 8, 1, 2, 6}},
+		{"three", "server", "client", 0, 3, []int{0, 2, 2, 2, 1}},
+		{"empty client seed", "seed", "", 7, 1000, []int{311, 245, 237, 946, 152}},
+		{"one", "x", "y", 2, 1, []int{0, 0, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rng := NewDrawRNG(test.serverSeed, test.clientSeed, test.nonce)
+			got := []int{}
+			for range test.want {
+				got = append(got, rng.Intn(test.n))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Intn(%d) = %v, want %v", test.n, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDrawRNGIntnRange(t *testing.T) {
+	for _, n := range []int{1, 2, 7, 68, 3000} {
+		rng := NewDrawRNG("server", "client", n)
+		for i := 0; i < 1000; i++ {
+			if got := rng.Intn(n); got < 0 || got >= n {
+				t.Fatalf("Intn(%d) = %d, out of range", n, got)
+			}
+		}
+	}
+}
+
+func testIDs(t *testing.T, hexes ...string) []primitive.ObjectID {
+	ids := []primitive.ObjectID{}
+	for _, h := range hexes {
+		id, err := primitive.ObjectIDFromHex(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestWithoutSkipped(t *testing.T) {
+	ids := testIDs(t,
+		"000000000000000000000001",
+		"000000000000000000000002",
+		"000000000000000000000003",
+	)
+	other := testIDs(t, "000000000000000000000009")[0]
+	tests := []struct {
+		name    string
+		skipped []primitive.ObjectID
+		want    []primitive.ObjectID
+		ok      bool
+	}{
+		{"nothing skipped", nil, ids, true},
+		{"one skipped", []primitive.ObjectID{ids[1]}, []primitive.ObjectID{ids[0], ids[2]}, true},
+		{"all skipped", []primitive.ObjectID{ids[2], ids[0], ids[1]}, []primitive.ObjectID{}, true},
+		{"not a candidate", []primitive.ObjectID{other}, nil, false},
+		{"skipped twice", []primitive.ObjectID{ids[0], ids[0]}, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := withoutSkipped(ids, test.skipped)
+			if ok != test.ok {
+				t.Fatalf("withoutSkipped ok = %v, want %v", ok, test.ok)
+			}
+			if ok && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("withoutSkipped = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReplayDraw(t *testing.T) {
+	ids := testIDs(t,
+		"000000000000000000000001",
+		"000000000000000000000002",
+		"000000000000000000000003",
+		"000000000000000000000004",
+	)
+	hash := HashPool(ids)
+	seed := structs.ServerSeed{Seed: "server", Hash: HashSeed("server"), Nonce: 5}
+
+	// nonce 3 rolls 2 of 4 and then 1 of 3, with odds it rolls 74 of 100,
+	// 1 of 4, 89 of 100 and 0 of 3
+	session := func() structs.Draw {
+		return structs.Draw{ClientSeed: "client", Nonce: 3, Picks: []structs.DrawPick{
+			{Pool: 4, PoolHash: hash, Index: 2, Player: ids[2]},
+			{Pool: 3, PoolHash: hash, Skipped: []primitive.ObjectID{ids[2]}, Index: 1, Player: ids[1]},
+		}}
+	}
+	pack := func() structs.Draw {
+		return structs.Draw{ClientSeed: "client", Nonce: 3, Picks: []structs.DrawPick{
+			{Total: 100, Roll: 74, Pool: 4, PoolHash: hash, Index: 1, Player: ids[1]},
+			{Total: 100, Roll: 89, Pool: 3, PoolHash: hash, Skipped: []primitive.ObjectID{ids[1]}, Index: 0, Player: ids[0]},
+		}}
+	}
+
+	tests := []struct {
+		name   string
+		draw   func() structs.Draw
+		change func(draw *structs.Draw, seed *structs.ServerSeed, pools map[string][]primitive.ObjectID)
+		want   bool
+	}{
+		{"session draw", session, nil, true},
+		{"pack draw", pack, nil, true},
+		{"wrong server seed", session, func(draw *structs.Draw, seed *structs.ServerSeed, pools map[string][]primitive.ObjectID) {
+			seed.Seed = "other"
+		}, false},
+		{"nonce not handed out yet", session, func(draw *structs.Draw, seed *structs.ServerSeed, pools map[string][]primitive.ObjectID) {
+			seed.Nonce = 2
+		}, false},
+		{"other client seed", session, func(draw *structs.Draw, seed *structs.ServerSeed, pools map[string][]primitive.ObjectID) {
+			draw.ClientSeed = "someone else"
+		}, false},
+		{"wrong index", session, func(draw *structs.Draw, seed *structs.ServerSeed, pools map[string][]primitive.ObjectID) {
+			draw.Picks[0].Index = 1
+			draw.Picks[0].Player = ids[1]
+		}, false},
+		{"wrong player", session, func(draw *structs.Draw, seed *structs.ServerSeed, pools map[string][]primitive.ObjectID) {
+			draw.Picks[1].Player = ids[3]
+		}, false},
+		{"skipped player left in the pool", session, func(draw *structs.Draw, seed *structs.ServerSeed, pools map[string][]primitive.ObjectID) {
+			draw.Picks[1].Skipped = nil
+		}, false},
+		{"wrong roll", pack, func(draw *structs.Draw, seed *structs.ServerSeed, pools map[string][]primitive.ObjectID) {
+			draw.Picks[0].Roll = 10
+		}, false},
+		{"missing pool", session, func(draw *structs.Draw, seed *structs.ServerSeed, pools map[string][]primitive.ObjectID) {
+			delete(pools, hash)
+		}, false},
+		{"pool does not match its hash", session, func(draw *structs.Draw, seed *structs.ServerSeed, pools map[string][]primitive.ObjectID) {
+			pools[hash] = []primitive.ObjectID{ids[3], ids[2], ids[1], ids[0]}
+		}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			draw, seed := test.draw(), seed
+			pools := map[string][]primitive.ObjectID{hash: ids}
+			if test.change != nil {
+				test.change(&draw, &seed, pools)
+			}
+			if got := ReplayDraw(draw, seed, pools); got != test.want {
+				t.Errorf("ReplayDraw = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package helper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"sort"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sample-start
+// Pack draws sample from a per-tier index in Redis: a sorted set of player
+// ids which all share the score 0, so its ranks follow the id order. A draw
+// reads the set size, a few ranks and one player document however large the
+// player collection is.
+
+var ErrPoolChanged = errors.New("player pool changed, try again")
+
+// poolKey names the index of an odds row within a pack filter.
+func poolKey(odds structs.PackOdds, filter *structs.PackFilter) string {
+	tier, _ := json.Marshal(struct {
+		MinOverall int                 `json:"minOverall"`
+		MaxOverall int                 `json:"maxOverall"`
+		Rarity     string              `json:"rarity"`
+		Leagues    []string            `json:"leagues"`
+		Filter     *structs.PackFilter `json:"filter"`
+	}{odds.MinOverall, odds.MaxOverall, odds.Rarity, odds.Leagues, filter})
+	hash := sha256.Sum256(tier)
+	return constant.POOLINDEXPREFIX + hex.EncodeToString(hash[:])
+}
+
+// poolIndex returns the size and fingerprint of an index, building it when it
+// is missing or expired.
+func poolIndex(conn redis.Conn, key string, odds structs.PackOdds, filter *structs.PackFilter) (int, string, error) {
+	hash, err := redis.String(conn.Do("GET", key+":hash"))
+	if err == redis.ErrNil {
+		return buildPoolIndex(conn, key, odds, filter)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	count, err := redis.Int(conn.Do("ZCARD", key))
+	if err != nil {
+		return 0, "", err
+	}
+	return count, hash, nil
+}
+func buildPoolIndex(conn redis.Conn, key string, odds structs.PackOdds, filter *structs.PackFilter) (int, string, error) {
+	ids, err := candidateIDs(OddsFilter(odds, filter, nil))
+	if err != nil {
+		return 0, "", err
+	}
+	hash, err := SavePool(ids)
+	if err != nil {
+		return 0, "", err
+	}
+
+	err = conn.Send("MULTI")
+	if err != nil {
+		return 0, "", err
+	}
+	conn.Send("DEL", key)
+	for start := 0; start < len(ids); start += 1000 {
+		args := redis.Args{key}
+		for _, id := range ids[start:minInt(start+1000, len(ids))] {
+			args = args.Add(0, id.Hex())
+		}
+		conn.Send("ZADD", args...)
+	}
+	conn.Send("EXPIRE", key, constant.POOLINDEXTTL)
+	conn.Send("SET", key+":hash", hash, "EX", constant.POOLINDEXTTL)
+	_, err = conn.Do("EXEC")
+	if err != nil {
+		return 0, "", err
+	}
+
+	return len(ids), hash, nil
+}
+
+// ClearPoolIndexes drops every index, draws build them again from the
+// current players. Whatever changes players calls it once when done.
+func ClearPoolIndexes() error {
+	conn := GetRedisPool().Get()
+	defer conn.Close()
+
+	cursor := 0
+	for {
+		reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", constant.POOLINDEXPREFIX+"*", "COUNT", 1000))
+		if err != nil {
+			return err
+		}
+		keys := []string{}
+		_, err = redis.Scan(reply, &cursor, &keys)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			_, err = conn.Do("DEL", redis.Args{}.AddFlat(keys)...)
+			if err != nil {
+				return err
+			}
+		}
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// SamplePlayer draws one player of an odds row, skipping the excluded
+// players. The index is taken over the remaining players, the skipped ones
+// are recorded so a replay can rebuild them from the stored pool.
+func SamplePlayer(odds structs.PackOdds, filter *structs.PackFilter, excluded []primitive.ObjectID, rng *DrawRNG) (structs.Player, structs.DrawPick, error) {
+	conn := GetRedisPool().Get()
+	defer conn.Close()
+	return samplePlayer(conn, odds, filter, excluded, rng)
+}
+func samplePlayer(conn redis.Conn, odds structs.PackOdds, filter *structs.PackFilter, excluded []primitive.ObjectID, rng *DrawRNG) (structs.Player, structs.DrawPick, error) {
+	player := structs.Player{}
+	pick := structs.DrawPick{}
+
+	key := poolKey(odds, filter)
+	count, hash, err := poolIndex(conn, key, odds, filter)
+	if err != nil {
+		return player, pick, err
+	}
+
+	// a league's taken players can be many, their ranks are read in one round
+	// trip
+	for _, id := range excluded {
+		err = conn.Send("ZRANK", key, id.Hex())
+		if err != nil {
+			return player, pick, err
+		}
+	}
+	err = conn.Flush()
+	if err != nil {
+		return player, pick, err
+	}
+	ranks := []int{}
+	skipped := []primitive.ObjectID{}
+	for _, id := range excluded {
+		rank, err := redis.Int(conn.Receive())
+		if err == redis.ErrNil {
+			continue
+		}
+		if err != nil {
+			return player, pick, err
+		}
+		ranks = append(ranks, rank)
+		skipped = append(skipped, id)
+	}
+	sort.Ints(ranks)
+
+	pool := count - len(ranks)
+	if pool < 1 {
+		return player, pick, fmt.Errorf("no player left in the %d-%d band", odds.MinOverall, odds.MaxOverall)
+	}
+	index := rng.Intn(pool)
+	rank := index
+	for _, taken := range ranks {
+		if taken <= rank {
+			rank++
+		}
+	}
+
+	ids, err := redis.Strings(conn.Do("ZRANGE", key, rank, rank))
+	if err != nil {
+		return player, pick, err
+	}
+	if len(ids) == 0 {
+		return player, pick, fmt.Errorf("player index %s changed during the draw", key)
+	}
+	player, err = GetPlayerByID(ids[0])
+	if err == mongo.ErrNoDocuments || (err == nil && player.Hidden) {
+		// the index is older than the players, the next draw builds it again
+		conn.Do("DEL", key, key+":hash")
+		return player, pick, ErrPoolChanged
+	}
+	if err != nil {
+		return player, pick, err
+	}
+
+	pick = structs.DrawPick{
+		Pool:     pool,
+		PoolHash: hash,
+		Skipped:  skipped,
+		Index:    index,
+		Player:   player.ID,
+	}
+	return player, pick, nil
+}
+
+// DrawBenchmark counts what one strategy read: Documents are the documents
+// MongoDB returned, RedisCommands the commands sent to Redis and
+// RedisRoundTrips how often the draw waited for Redis to answer.
+type DrawBenchmark struct {
+	Name            string
+	Rounds          int
+	Elapsed         time.Duration
+	Documents       int
+	RedisCommands   int
+	RedisRoundTrips int
+}
+
+// countingConn counts the commands and round trips of a Redis connection,
+// pipelined commands share the round trip of their flush.
+type countingConn struct {
+	redis.Conn
+	commands   int
+	roundTrips int
+}
+
+func (c *countingConn) Do(command string, args ...interface{}) (interface{}, error) {
+	if command != "" {
+		c.commands++
+	}
+	c.roundTrips++
+	return c.Conn.Do(command, args...)
+}
+func (c *countingConn) Send(command string, args ...interface{}) error {
+	c.commands++
+	return c.Conn.Send(command, args...)
+}
+func (c *countingConn) Flush() error {
+	c.roundTrips++
+	return c.Conn.Flush()
+}
+
+// BenchmarkDraws opens a pack rounds times with each way of picking players:
+// "load" reads up to 1000 full player documents per pick like packs used to,
+// "scan" reads every matching id and "index" samples the Redis index.
+func BenchmarkDraws(pack structs.PackDefinition, rounds int) ([]DrawBenchmark, error) {
+	client, err := GetMongoClient()
+	if err != nil {
+		return nil, err
+	}
+	collection := client.Database(constant.DB).Collection(constant.PLAYERS)
+	conn := &countingConn{Conn: GetRedisPool().Get()}
+	defer conn.Close()
+
+	strategies := []struct {
+		name string
+		pick func(odds structs.PackOdds, drawn []primitive.ObjectID, rng *DrawRNG) (structs.Player, int, error)
+	}{
+		{"load", func(odds structs.PackOdds, drawn []primitive.ObjectID, rng *DrawRNG) (structs.Player, int, error) {
+			players := []structs.Player{}
+			cursor, err := collection.Find(context.TODO(), OddsFilter(odds, pack.Filter, drawn), options.Find().SetLimit(1000))
+			if err != nil {
+				return structs.Player{}, 0, err
+			}
+			if err = cursor.All(context.TODO(), &players); err != nil || len(players) == 0 {
+				return structs.Player{}, len(players), err
+			}
+			return players[rng.Intn(len(players))], len(players), nil
+		}},
+		{"scan", func(odds structs.PackOdds, drawn []primitive.ObjectID, rng *DrawRNG) (structs.Player, int, error) {
+			ids, err := candidateIDs(OddsFilter(odds, pack.Filter, drawn))
+			if err != nil || len(ids) == 0 {
+				return structs.Player{}, len(ids), err
+			}
+			player, err := GetPlayerByID(ids[rng.Intn(len(ids))].Hex())
+			return player, len(ids) + 1, err
+		}},
+		{"index", func(odds structs.PackOdds, drawn []primitive.ObjectID, rng *DrawRNG) (structs.Player, int, error) {
+			player, _, err := samplePlayer(conn, odds, pack.Filter, drawn, rng)
+			return player, 1, err
+		}},
+	}
+
+	// build the indexes up front, they are shared by every later draw
+	warmup := NewDrawRNG(primitive.NewObjectID().Hex(), "warmup", 0)
+	for _, odds := range append(append([]structs.PackOdds{}, pack.Odds...), guaranteedOdds(pack)...) {
+		_, _, err = samplePlayer(conn, odds, pack.Filter, nil, warmup)
+		if err != nil {
+			return nil, err
+		}
+	}
+	conn.commands, conn.roundTrips = 0, 0
+
+	benchmarks := []DrawBenchmark{}
+	for _, strategy := range strategies {
+		benchmark := DrawBenchmark{Name: strategy.name, Rounds: rounds}
+		start := time.Now()
+		for round := 0; round < rounds; round++ {
+			rng := NewDrawRNG(primitive.NewObjectID().Hex(), strategy.name, round)
+			drawn := []primitive.ObjectID{}
+			for _, table := range packTables(pack) {
+				odds, _, _ := PickOdds(table, rng)
+				player, documents, err := strategy.pick(odds, drawn, rng)
+				if err != nil {
+					return benchmarks, err
+				}
+				benchmark.Documents += documents
+				drawn = append(drawn, player.ID)
+			}
+		}
+		benchmark.Elapsed = time.Since(start)
+		benchmark.RedisCommands, benchmark.RedisRoundTrips = conn.commands, conn.roundTrips
+		conn.commands, conn.roundTrips = 0, 0
+		benchmarks = append(benchmarks, benchmark)
+	}
+
+	return benchmarks, nil
+}
+func guaranteedOdds(pack structs.PackDefinition) []structs.PackOdds {
+	odds := []structs.PackOdds{}
+	for _, slot := range pack.Guarantees {
+		odds = append(odds, slot.Odds...)
+	}
+	return odds
+}
+
+// sample-end
@@ -0,0 +1,315 @@
+package helper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"manager-sensin/constant"
+	"manager-sensin/request"
+	"manager-sensin/structs"
+	"sort"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// session-start
+// A draw session keeps its own pool of player ids in a Redis sorted set under
+// draw:<id>, every id scores 0 so the ranks follow the id order like the
+// candidate list stored under draw:<id>:hash. The players drawn so far are in
+// the list draw:<id>:drawn and the request the session was created with in
+// draw:<id>:filter. All four keys expire together. Picks are rolled with the
+// committed server seed and saved as draws, the drawn players are the
+// skipped candidates of each pick so a replay can rebuild what was left.
+
+var (
+	ErrSessionNotFound = errors.New("draw session not found or expired")
+	ErrSessionEmpty    = errors.New("every player of the draw session has been drawn")
+	ErrSessionChanged  = errors.New("the draw session changed meanwhile, try again")
+)
+
+// fillScript replaces the pool of a session, with only-if-missing set it
+// leaves a session which still exists alone.
+var fillScript = redis.NewScript(4, `
+if ARGV[1] == "1" and redis.call("EXISTS", KEYS[3]) == 1 then
+	return 0
+end
+redis.call("DEL", KEYS[1], KEYS[2])
+for i = 5, #ARGV, 1000 do
+	local args = {}
+	for j = i, math.min(i + 999, #ARGV) do
+		args[#args + 1] = 0
+		args[#args + 1] = ARGV[j]
+	end
+	redis.call("ZADD", KEYS[1], unpack(args))
+end
+redis.call("EXPIRE", KEYS[1], ARGV[2])
+redis.call("SET", KEYS[3], ARGV[3], "EX", ARGV[2])
+redis.call("SET", KEYS[4], ARGV[4], "EX", ARGV[2])
+return 1
+`)
+
+// pickScript moves the player at the rolled rank from the pool to the drawn
+// list in one step, as long as the pool is still the one the rank was rolled
+// over. The drawn list inherits the expiry of the session.
+var pickScript = redis.NewScript(4, `
+if redis.call("EXISTS", KEYS[3]) == 0 then
+	return redis.error_reply("missing")
+end
+if redis.call("GET", KEYS[4]) ~= ARGV[1] or redis.call("ZCARD", KEYS[1]) ~= tonumber(ARGV[2]) then
+	return redis.error_reply("changed")
+end
+local id = redis.call("ZRANGE", KEYS[1], ARGV[3], ARGV[3])[1]
+redis.call("ZREM", KEYS[1], id)
+redis.call("RPUSH", KEYS[2], id)
+redis.call("PEXPIRE", KEYS[2], redis.call("PTTL", KEYS[3]))
+return id
+`)
+
+func sessionKeys(id string) (string, string, string, string) {
+	key := constant.DRAWSESSIONPREFIX + id
+	return key, key + ":drawn", key + ":filter", key + ":hash"
+}
+
+// CreateDrawSession fills a new session with the players matching the filter
+// and returns its id, pool size and expiry.
+func CreateDrawSession(session request.DrawSessionRequest) (string, int, time.Time, error) {
+	token := make([]byte, 16)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", 0, time.Time{}, err
+	}
+	id := hex.EncodeToString(token)
+
+	size, expiresAt, err := fillDrawSession(id, session, false)
+	if err != nil {
+		return "", 0, time.Time{}, err
+	}
+	return id, size, expiresAt, nil
+}
+
+// ResetDrawSession puts every player back into the pool, clears the drawn
+// list and starts the expiry over, it returns the pool size and new expiry.
+func ResetDrawSession(id string) (int, time.Time, error) {
+	session, err := drawSessionRequest(id)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return fillDrawSession(id, session, false)
+}
+func drawSessionRequest(id string) (request.DrawSessionRequest, error) {
+	session := request.DrawSessionRequest{}
+	conn := GetRedisPool().Get()
+	defer conn.Close()
+
+	_, _, filterKey, _ := sessionKeys(id)
+	data, err := redis.Bytes(conn.Do("GET", filterKey))
+	if err == redis.ErrNil {
+		return session, ErrSessionNotFound
+	}
+	if err != nil {
+		return session, err
+	}
+	err = json.Unmarshal(data, &session)
+	return session, err
+}
+func fillDrawSession(id string, session request.DrawSessionRequest, onlyIfMissing bool) (int, time.Time, error) {
+	ids, err := sessionPool(session.Filter)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	hash, err := SavePool(ids)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	conn := GetRedisPool().Get()
+	defer conn.Close()
+
+	key, drawnKey, filterKey, hashKey := sessionKeys(id)
+	seconds := session.Minutes * 60
+	expiresAt := time.Now().Add(time.Duration(seconds) * time.Second)
+	args := redis.Args{key, drawnKey, filterKey, hashKey, onlyIfMissing, seconds, data, hash}
+	for _, id := range ids {
+		args = args.Add(id.Hex())
+	}
+	_, err = fillScript.Do(conn, args...)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return len(ids), expiresAt, nil
+}
+
+// sessionPool lists the ids of the players matching the filter in id order,
+// the unfiltered pool is limited to the best ALLPLAYERLIMIT players.
+func sessionPool(f request.Filter) ([]primitive.ObjectID, error) {
+	ids := []primitive.ObjectID{}
+	client, err := GetMongoClient()
+	if err != nil {
+		return ids, err
+	}
+
+	findOptions := options.Find().SetProjection(bson.M{"_id": 1}).SetSort(constant.OVERALLOPTION)
+	if GenerateRedisKey(&f) == constant.ALLPLAYERS {
+		findOptions.SetLimit(constant.ALLPLAYERLIMIT)
+	}
+	documents := []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}{}
+	cursor, err := client.Database(constant.DB).Collection(constant.PLAYERS).Find(context.TODO(), AddFilterViaFields(&f), findOptions)
+	if err != nil {
+		return ids, err
+	}
+	if err = cursor.All(context.TODO(), &documents); err != nil {
+		return ids, err
+	}
+	for _, document := range documents {
+		ids = append(ids, document.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Hex() < ids[j].Hex()
+	})
+
+	return ids, nil
+}
+
+// PickFromSession draws a player of the session with the server seed and
+// saves the draw. An empty client seed falls back to the one the session was
+// created with. It returns the player id, the draw and how many are left.
+func PickFromSession(id, clientSeed, managerID string) (string, structs.Draw, int, error) {
+	draw := structs.Draw{}
+	conn := GetRedisPool().Get()
+	defer conn.Close()
+
+	key, drawnKey, filterKey, hashKey := sessionKeys(id)
+	conn.Send("MULTI")
+	conn.Send("GET", filterKey)
+	conn.Send("GET", hashKey)
+	conn.Send("ZCARD", key)
+	conn.Send("LRANGE", drawnKey, 0, -1)
+	reply, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return "", draw, 0, err
+	}
+	data, err := redis.Bytes(reply[0], nil)
+	if err == redis.ErrNil {
+		return "", draw, 0, ErrSessionNotFound
+	}
+	if err != nil {
+		return "", draw, 0, err
+	}
+	hash, _ := redis.String(reply[1], nil)
+	count, _ := redis.Int(reply[2], nil)
+	drawn, _ := redis.Strings(reply[3], nil)
+	if count == 0 {
+		return "", draw, 0, ErrSessionEmpty
+	}
+
+	if clientSeed == "" {
+		session := request.DrawSessionRequest{}
+		err = json.Unmarshal(data, &session)
+		if err != nil {
+			return "", draw, 0, err
+		}
+		clientSeed = session.ClientSeed
+	}
+	draw, rng, err := NextDraw(constant.DRAWPLAYER, clientSeed)
+	if err != nil {
+		return "", draw, 0, err
+	}
+	index := rng.Intn(count)
+
+	player, err := redis.String(pickScript.Do(conn, key, drawnKey, filterKey, hashKey, hash, count, index))
+	if err != nil {
+		if redisError, ok := err.(redis.Error); ok && redisError.Error() == "missing" {
+			return "", draw, 0, ErrSessionNotFound
+		}
+		if redisError, ok := err.(redis.Error); ok && redisError.Error() == "changed" {
+			return "", draw, 0, ErrSessionChanged
+		}
+		return "", draw, 0, err
+	}
+
+	skipped := []primitive.ObjectID{}
+	for _, drawnID := range drawn {
+		objectID, err := primitive.ObjectIDFromHex(drawnID)
+		if err != nil {
+			return "", draw, 0, err
+		}
+		skipped = append(skipped, objectID)
+	}
+	playerID, err := primitive.ObjectIDFromHex(player)
+	if err != nil {
+		return "", draw, 0, err
+	}
+	draw.Manager = managerID
+	draw.Picks = append(draw.Picks, structs.DrawPick{
+		Pool:     count,
+		PoolHash: hash,
+		Skipped:  skipped,
+		Index:    index,
+		Player:   playerID,
+	})
+	err = SaveDraw(&draw)
+	if err != nil {
+		return "", draw, 0, err
+	}
+
+	return player, draw, count - 1, nil
+}
+
+// PickRandomPlayer draws from the session every /player/random request with
+// the same filter shares, filling it when it is missing or expired.
+func PickRandomPlayer(f request.Filter, managerID string) (string, structs.Draw, int, error) {
+	id := constant.RANDOMSESSIONPREFIX + GenerateRedisKey(&f)
+	player, draw, remaining, err := PickFromSession(id, f.ClientSeed, managerID)
+	if err != ErrSessionNotFound {
+		return player, draw, remaining, err
+	}
+
+	session := request.DrawSessionRequest{Filter: f, Minutes: constant.RANDOMSESSIONMINUTES}
+	session.ClientSeed = ""
+	_, _, err = fillDrawSession(id, session, true)
+	if err != nil {
+		return "", draw, 0, err
+	}
+	return PickFromSession(id, f.ClientSeed, managerID)
+}
+
+// GetDrawSession returns the number of players left, the ids drawn so far in
+// order and when the session expires.
+func GetDrawSession(id string) (int, []string, time.Time, error) {
+	conn := GetRedisPool().Get()
+	defer conn.Close()
+
+	key, drawnKey, filterKey, _ := sessionKeys(id)
+	ttl, err := redis.Int(conn.Do("TTL", filterKey))
+	if err != nil {
+		return 0, nil, time.Time{}, err
+	}
+	if ttl < 0 {
+		return 0, nil, time.Time{}, ErrSessionNotFound
+	}
+	remaining, err := redis.Int(conn.Do("ZCARD", key))
+	if err != nil {
+		return 0, nil, time.Time{}, err
+	}
+	drawn, err := redis.Strings(conn.Do("LRANGE", drawnKey, 0, -1))
+	if err != nil {
+		return 0, nil, time.Time{}, err
+	}
+
+	return remaining, drawn, time.Now().Add(time.Duration(ttl) * time.Second), nil
+}
+
+// session-end
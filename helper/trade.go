@@ -0,0 +1,316 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"manager-sensin/constant"
+	"manager-sensin/request"
+	"manager-sensin/structs"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// trade-start
+var (
+	ErrTradeClosed  = errors.New("trade is not pending anymore")
+	ErrTradeChanged = errors.New("players or points of the trade changed meanwhile")
+)
+
+func tradeCollection() (*mongo.Collection, error) {
+	client, err := GetMongoClient()
+	if err != nil {
+		return nil, err
+	}
+	err = PrepareCollections()
+	if err != nil {
+		return nil, err
+	}
+	return client.Database(constant.DB).Collection(constant.TRADES), nil
+}
+
+// OfferSide builds one side of a trade from the players and points the
+// manager puts in.
+func OfferSide(manager structs.Manager, offer request.TradeOffer) (structs.TradeSide, error) {
+	side := structs.TradeSide{Players: []structs.Player{}, Points: offer.Points}
+	if offer.Points < 0 {
+		return side, fmt.Errorf("points of a trade can't be negative")
+	}
+
+	seen := make(map[string]bool)
+	for _, id := range offer.Players {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		found, player := manager.OwnedPlayer(id)
+		if !found {
+			return side, fmt.Errorf("%s doesn't own player %s", manager.Name, id)
+		}
+		side.Players = append(side.Players, player)
+	}
+
+	return side, CheckSide(manager, side)
+}
+
+// CheckSide makes sure the manager can still give everything the side offers,
+// players on the transfer market can't be traded.
+func CheckSide(manager structs.Manager, side structs.TradeSide) error {
+	for _, player := range side.Players {
+		if !manager.Owns(player.ID) {
+			return fmt.Errorf("%s doesn't own %s anymore", manager.Name, player.Name)
+		}
+	}
+	if manager.Points < side.Points {
+		return fmt.Errorf("%s doesn't have %d points", manager.Name, side.Points)
+	}
+
+	listed, err := ListedPlayers(manager.ID.Hex())
+	if err != nil {
+		return err
+	}
+	for _, player := range side.Players {
+		if listed[player.ID.Hex()] {
+			return fmt.Errorf("%s is on the transfer market", player.Name)
+		}
+	}
+	return nil
+}
+
+// CreateTrade proposes a trade from one manager to another. A counter also
+// closes the trade it answers, both writes happen in one transaction.
+func CreateTrade(from, to structs.Manager, offered, requested structs.TradeSide, counterOf *structs.Trade) (structs.Trade, error) {
+	trade := structs.Trade{
+		ID:        primitive.NewObjectID(),
+		League:    from.League,
+		From:      from.ID.Hex(),
+		FromName:  from.Name,
+		To:        to.ID.Hex(),
+		ToName:    to.Name,
+		Offered:   offered,
+		Requested: requested,
+		Status:    constant.TRADEPENDING,
+		CreatedAt: time.Now(),
+	}
+	collection, err := tradeCollection()
+	if err != nil {
+		return trade, err
+	}
+
+	if counterOf == nil {
+		_, err = collection.InsertOne(context.TODO(), trade)
+		return trade, err
+	}
+
+	trade.CounterOf = counterOf.ID.Hex()
+	err = WithTransaction(func(ctx mongo.SessionContext) error {
+		counterOf.CounteredBy = trade.ID.Hex()
+		err := closeTrade(ctx, collection, counterOf, constant.TRADECOUNTERED, trade.CreatedAt)
+		if err != nil {
+			return err
+		}
+		_, err = collection.InsertOne(ctx, trade)
+		return err
+	})
+	if err != nil {
+		counterOf.CounteredBy = ""
+		return trade, err
+	}
+
+	return trade, nil
+}
+func GetTradeByID(id string) (structs.Trade, error) {
+	trade := structs.Trade{}
+
+	tradeID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return trade, err
+	}
+	result, err := GetSingleResultByID(tradeID, constant.TRADES)
+	if err != nil {
+		return trade, err
+	}
+
+	err = result.Decode(&trade)
+	if err != nil {
+		return trade, err
+	}
+
+	return trade, nil
+}
+
+// GetTrades lists the trades a manager made or received, newest first,
+// optionally only the ones in status.
+func GetTrades(managerID, status string) ([]structs.Trade, error) {
+	trades := []structs.Trade{}
+	collection, err := tradeCollection()
+	if err != nil {
+		return trades, err
+	}
+
+	filter := bson.M{"$or": bson.A{bson.M{"from": managerID}, bson.M{"to": managerID}}}
+	if status != "" {
+		filter["status"] = status
+	}
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return trades, err
+	}
+	if err = cursor.All(context.TODO(), &trades); err != nil {
+		return trades, err
+	}
+
+	return trades, nil
+}
+
+// CloseTrade rejects or cancels a pending trade.
+func CloseTrade(trade *structs.Trade, status string) error {
+	collection, err := tradeCollection()
+	if err != nil {
+		return err
+	}
+	return closeTrade(context.TODO(), collection, trade, status, time.Now())
+}
+func closeTrade(ctx context.Context, collection *mongo.Collection, trade *structs.Trade, status string, now time.Time) error {
+	set := bson.M{"status": status, "closedAt": now}
+	if trade.CounteredBy != "" {
+		set["counteredBy"] = trade.CounteredBy
+	}
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": trade.ID, "status": constant.TRADEPENDING}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrTradeClosed
+	}
+	trade.Status = status
+	trade.ClosedAt = &now
+	return nil
+}
+
+// AcceptTrade swaps the players and points of both sides in one transaction.
+// Every write is guarded by what the managers have to own, when one of them
+// sold, traded or spent something meanwhile nothing changes.
+func AcceptTrade(trade *structs.Trade, from, to structs.Manager) error {
+	collection, err := tradeCollection()
+	if err != nil {
+		return err
+	}
+	client, err := GetMongoClient()
+	if err != nil {
+		return err
+	}
+	listings := client.Database(constant.DB).Collection(constant.LISTINGS)
+
+	// players move as they are now, not as they were when offered
+	offered := currentSide(from, trade.Offered)
+	requested := currentSide(to, trade.Requested)
+
+	return WithTransaction(func(ctx mongo.SessionContext) error {
+		err := closeTrade(ctx, collection, trade, constant.TRADEACCEPTED, time.Now())
+		if err != nil {
+			return err
+		}
+
+		listed, err := listings.CountDocuments(ctx, bson.M{
+			"status": constant.LISTINGOPEN,
+			"$or": bson.A{
+				bson.M{"seller": trade.From, "player._id": bson.M{"$in": offered.PlayerIDs()}},
+				bson.M{"seller": trade.To, "player._id": bson.M{"$in": requested.PlayerIDs()}},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if listed > 0 {
+			return ErrTradeChanged
+		}
+
+		err = exchange(ctx, from.ID, offered, requested, trade.ToName, trade.To)
+		if err != nil {
+			return err
+		}
+		err = exchange(ctx, to.ID, requested, offered, trade.FromName, trade.To)
+		if err != nil {
+			return err
+		}
+
+		// both sides let go before either claims, the claims swap owners
+		err = ReleasePlayers(ctx, trade.League, trade.From, offered.PlayerIDs())
+		if err != nil {
+			return err
+		}
+		err = ReleasePlayers(ctx, trade.League, trade.To, requested.PlayerIDs())
+		if err != nil {
+			return err
+		}
+		err = ClaimPlayers(ctx, trade.League, trade.From, requested.PlayerIDs())
+		if err != nil {
+			return err
+		}
+		return ClaimPlayers(ctx, trade.League, trade.To, offered.PlayerIDs())
+	})
+}
+func currentSide(manager structs.Manager, side structs.TradeSide) structs.TradeSide {
+	current := structs.TradeSide{Players: []structs.Player{}, Points: side.Points}
+	for _, player := range side.Players {
+		if found, owned := manager.OwnedPlayer(player.ID.Hex()); found {
+			player = owned
+		}
+		current.Players = append(current.Players, player)
+	}
+	return current
+}
+
+// exchange takes what the manager gives and hands over what they get.
+func exchange(ctx mongo.SessionContext, managerID primitive.ObjectID, gives, gets structs.TradeSide, partner, actor string) error {
+	owned := bson.M{}
+	if len(gives.Players) > 0 {
+		owned["$all"] = gives.PlayerIDs()
+	}
+	if len(gets.Players) > 0 {
+		owned["$nin"] = gets.PlayerIDs()
+	}
+	condition := bson.M{}
+	if len(owned) > 0 {
+		condition["players._id"] = owned
+	}
+	var update bson.M
+	if len(gives.Players) > 0 {
+		update = bson.M{"$pull": bson.M{"players": bson.M{"_id": bson.M{"$in": gives.PlayerIDs()}}}}
+	}
+
+	reason := fmt.Sprintf("trade with %s", partner)
+	entries := []structs.LedgerEntry{}
+	if gives.Points > 0 {
+		entries = append(entries, structs.LedgerEntry{Amount: -gives.Points, Source: constant.LEDGERTRADE, Reason: reason, Actor: actor})
+	}
+	if gets.Points > 0 {
+		entries = append(entries, structs.LedgerEntry{Amount: gets.Points, Source: constant.LEDGERTRADE, Reason: reason, Actor: actor})
+	}
+
+	_, err := ApplyPoints(ctx, managerID, condition, update, entries...)
+	if err == mongo.ErrNoDocuments || err == ErrInsufficientPoints {
+		return ErrTradeChanged
+	}
+	if err != nil {
+		return err
+	}
+	if len(gets.Players) == 0 {
+		return nil
+	}
+
+	client, err := GetMongoClient()
+	if err != nil {
+		return err
+	}
+	_, err = client.Database(constant.DB).Collection(constant.MANAGERS).UpdateOne(ctx, bson.M{"_id": managerID},
+		bson.M{"$push": bson.M{"players": bson.M{"$each": gets.Players}}})
+	return err
+}
+
+// trade-end
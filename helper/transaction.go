@@ -0,0 +1,213 @@
+package helper
+
+import (
+	"context"
+	"manager-sensin/constant"
+	"manager-sensin/structs"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// transaction-start
+// transactional lists the collections written inside transactions, they
+// can't be created there.
+var transactional = []string{
+	constant.MANAGERS,
+	constant.LEAGUES,
+	constant.LEDGER,
+	constant.DRAWS,
+	constant.PACKOPENINGS,
+	constant.DRAFTS,
+	constant.PLAYERCLAIMS,
+	constant.LISTINGS,
+	constant.TRADES,
+}
+var indexes = map[string][]mongo.IndexModel{
+	// one manager per account, managers added without one aren't indexed
+	constant.MANAGERS: {
+		{Keys: bson.D{{Key: "userID", Value: 1}}, Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"userID": bson.M{"$gt": ""}})},
+	},
+	constant.PACKS: {
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	constant.LEAGUES: {
+		{Keys: bson.D{{Key: "inviteCode", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	constant.PLAYERCLAIMS: {
+		{Keys: bson.D{{Key: "league", Value: 1}, {Key: "manager", Value: 1}}},
+		{Keys: bson.D{{Key: "manager", Value: 1}}},
+	},
+	constant.PACKOPENINGS: {
+		{Keys: bson.D{{Key: "manager", Value: 1}, {Key: "at", Value: -1}}},
+		{Keys: bson.D{{Key: "league", Value: 1}, {Key: "at", Value: -1}}},
+	},
+	constant.LISTINGS: {
+		{Keys: bson.D{{Key: "active", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "endsAt", Value: 1}}},
+	},
+}
+var prepared struct {
+	sync.Mutex
+	done  bool
+	packs bool
+}
+
+// PrepareCollections creates the transactional collections and the indexes
+// once per process, a failed attempt is retried by the next call. The default
+// packs are stored when the pack collection doesn't exist yet, so packs an
+// admin deleted stay deleted.
+func PrepareCollections() error {
+	prepared.Lock()
+	defer prepared.Unlock()
+	if prepared.done {
+		return nil
+	}
+
+	client, err := GetMongoClient()
+	if err != nil {
+		return err
+	}
+	db := client.Database(constant.DB)
+	names, err := db.ListCollectionNames(context.TODO(), bson.M{"name": constant.PACKS})
+	if err != nil {
+		return err
+	}
+	// remembered, the index below creates the collection even when seeding
+	// fails afterwards
+	prepared.packs = prepared.packs || len(names) == 0
+	for _, collection := range transactional {
+		err = CreateCollection(db, collection)
+		if err != nil {
+			return err
+		}
+	}
+	for collection, models := range indexes {
+		_, err = db.Collection(collection).Indexes().CreateMany(context.TODO(), models)
+		if err != nil {
+			return err
+		}
+	}
+
+	if prepared.packs {
+		err = seedPacks(db)
+		if err != nil {
+			return err
+		}
+		prepared.packs = false
+	}
+
+	prepared.done = true
+	return nil
+}
+
+// WithTransaction runs fn inside a MongoDB transaction, every write done with
+// the given session context is committed together or not at all.
+func WithTransaction(fn func(ctx mongo.SessionContext) error) error {
+	client, err := GetMongoClient()
+	if err != nil {
+		return err
+	}
+
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.TODO())
+
+	_, err = session.WithTransaction(context.TODO(), func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
+
+// RecordResult stores a new result together with the already updated season
+// whose fixtures or bracket point to it.
+func RecordResult(result structs.Result, season *structs.Season) error {
+	client, err := GetMongoClient()
+	if err != nil {
+		return err
+	}
+	db := client.Database(constant.DB)
+	err = CreateCollection(db, constant.RESULTS)
+	if err != nil {
+		return err
+	}
+
+	return WithTransaction(func(ctx mongo.SessionContext) error {
+		_, err := db.Collection(constant.RESULTS).InsertOne(ctx, result)
+		if err != nil {
+			return err
+		}
+		return replaceSeason(ctx, db, season)
+	})
+}
+
+// ReviseResult replaces a result, persists the updated season and records the
+// audit entry in the same transaction.
+func ReviseResult(result structs.Result, season *structs.Season, audit structs.ResultAudit) error {
+	client, err := GetMongoClient()
+	if err != nil {
+		return err
+	}
+	db := client.Database(constant.DB)
+	err = CreateCollection(db, constant.RESULTAUDITS)
+	if err != nil {
+		return err
+	}
+
+	return WithTransaction(func(ctx mongo.SessionContext) error {
+		_, err := db.Collection(constant.RESULTS).ReplaceOne(ctx, bson.M{"_id": result.ID}, result)
+		if err != nil {
+			return err
+		}
+		err = replaceSeason(ctx, db, season)
+		if err != nil {
+			return err
+		}
+		_, err = db.Collection(constant.RESULTAUDITS).InsertOne(ctx, audit)
+		return err
+	})
+}
+
+// RemoveResult deletes a result, persists the season it was unplayed from and
+// records the audit entry in the same transaction.
+func RemoveResult(id primitive.ObjectID, season *structs.Season, audit structs.ResultAudit) error {
+	client, err := GetMongoClient()
+	if err != nil {
+		return err
+	}
+	db := client.Database(constant.DB)
+	err = CreateCollection(db, constant.RESULTAUDITS)
+	if err != nil {
+		return err
+	}
+
+	return WithTransaction(func(ctx mongo.SessionContext) error {
+		_, err := db.Collection(constant.RESULTS).DeleteOne(ctx, bson.M{"_id": id})
+		if err != nil {
+			return err
+		}
+		err = replaceSeason(ctx, db, season)
+		if err != nil {
+			return err
+		}
+		_, err = db.Collection(constant.RESULTAUDITS).InsertOne(ctx, audit)
+		return err
+	})
+}
+
+func replaceSeason(ctx context.Context, db *mongo.Database, season *structs.Season) error {
+	update, err := fieldsUpdate(season)
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection(constant.SEASONS).UpdateOne(ctx, bson.M{"_id": season.ID}, update)
+	return err
+}
+
+// transaction-end
@@ -2,19 +2,24 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"manager-sensin/constant"
 	"manager-sensin/helper"
 	"manager-sensin/request"
 	"manager-sensin/structs"
-	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // player-start
@@ -84,60 +89,159 @@ func searchPlayer(w http.ResponseWriter, r *http.Request) {
 }
 func randomPlayer(w http.ResponseWriter, r *http.Request) {
 	var f request.Filter
-	var players []structs.Player
-	var player structs.Player
-	limit := 0
-
 	err := json.NewDecoder(r.Body).Decode(&f)
 	if err != nil {
 		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
 		return
 	}
 
-	key := helper.GenerateRedisKey(&f)
-	if key == constant.ALLPLAYERS {
-		limit = constant.ALLPLAYERLIMIT
+	managerID := ""
+	if caller, found := helper.Caller(r); found {
+		managerID = caller.ID.Hex()
+	}
+	playerID, draw, remaining, err := helper.PickRandomPlayer(f, managerID)
+	if err == helper.ErrSessionEmpty {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("no player left for this filter"), constant.SEARCHPLAYERERROR)
+		return
+	}
+	if err == helper.ErrSessionChanged {
+		helper.ReturnError(w, http.StatusConflict, err, constant.DRAWERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DRAWERROR)
+		return
 	}
 
-	pool := helper.GetRedisPool()
-	exists, err := helper.CheckRedisData(pool, key)
+	player, err := helper.GetPlayerByID(playerID)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.CHECKREDISERROR)
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETPLAYERERROR)
 		return
 	}
 
-	if exists {
-		err = helper.GetRedisData(pool, key, &players)
-		if err != nil {
-			helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETREDISERROR)
-			return
-		}
-	} else {
-		filter := helper.AddFilterViaFields(&f)
-		players, err = helper.SearchPlayerByFilter(filter, constant.OVERALLOPTION, int64(limit))
-		if err != nil {
-			helper.ReturnError(w, http.StatusInternalServerError, err, constant.SEARCHPLAYERERROR)
-			return
-		}
+	json.NewEncoder(w).Encode(request.Response{
+		Count:   remaining,
+		Players: []structs.Player{player},
+		Draw:    draw.ID.Hex(),
+	})
+}
+
+// player-end
+// draw-start
+func createDrawSession(w http.ResponseWriter, r *http.Request) {
+	var session request.DrawSessionRequest
+	err := json.NewDecoder(r.Body).Decode(&session)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	if session.Minutes == 0 {
+		session.Minutes = constant.DRAWSESSIONMINUTES
+	}
+	if session.Minutes < 1 || session.Minutes > constant.DRAWSESSIONMAXMINUTES {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("minutes should be between 1 and %d", constant.DRAWSESSIONMAXMINUTES), constant.DRAWSESSIONERROR)
+		return
+	}
+
+	id, size, expiresAt, err := helper.CreateDrawSession(session)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DRAWSESSIONERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(request.DrawSessionResponse{
+		ID:        id,
+		Remaining: size,
+		Drawn:     []structs.Player{},
+		ExpiresAt: expiresAt,
+	})
+}
+func getDrawSession(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	remaining, drawn, expiresAt, err := helper.GetDrawSession(id)
+	if err == helper.ErrSessionNotFound {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.DRAWSESSIONERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DRAWSESSIONERROR)
+		return
+	}
+
+	players, err := helper.GetPlayersByIDs(drawn)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETPLAYERERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(request.DrawSessionResponse{
+		ID:        id,
+		Remaining: remaining,
+		Drawn:     players,
+		ExpiresAt: expiresAt,
+	})
+}
+func drawFromSession(w http.ResponseWriter, r *http.Request) {
+	var f request.Filter
+	err := json.NewDecoder(r.Body).Decode(&f)
+	if err != nil && err != io.EOF {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
 	}
 
-	random := helper.GetRandom(0, len(players))
-	player = players[random]
-	players = append(players[:random], players[random+1:]...)
+	managerID := ""
+	if caller, found := helper.Caller(r); found {
+		managerID = caller.ID.Hex()
+	}
+	playerID, draw, remaining, err := helper.PickFromSession(mux.Vars(r)["id"], f.ClientSeed, managerID)
+	if err == helper.ErrSessionNotFound {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.DRAWSESSIONERROR)
+		return
+	}
+	if err == helper.ErrSessionEmpty || err == helper.ErrSessionChanged {
+		helper.ReturnError(w, http.StatusConflict, err, constant.DRAWSESSIONERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DRAWSESSIONERROR)
+		return
+	}
 
-	err = helper.SetRedisData(pool, key, players, 45*time.Minute)
+	player, err := helper.GetPlayerByID(playerID)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.SETREDISERROR)
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETPLAYERERROR)
 		return
 	}
 
 	json.NewEncoder(w).Encode(request.Response{
-		Count:   len(players),
+		Count:   remaining,
 		Players: []structs.Player{player},
+		Draw:    draw.ID.Hex(),
 	})
 }
+func resetDrawSession(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	size, expiresAt, err := helper.ResetDrawSession(id)
+	if err == helper.ErrSessionNotFound {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.DRAWSESSIONERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DRAWSESSIONERROR)
+		return
+	}
 
-// player-end
+	json.NewEncoder(w).Encode(request.DrawSessionResponse{
+		ID:        id,
+		Remaining: size,
+		Drawn:     []structs.Player{},
+		ExpiresAt: expiresAt,
+	})
+}
+
+// draw-end
 // manager-start
 func createManager(w http.ResponseWriter, r *http.Request) {
 	var m structs.Manager
@@ -147,7 +251,18 @@ func createManager(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	token, _ := helper.Token(r)
+	m.UserID = token.Subject
+	m.Role = ""
+	if m.Email == "" {
+		m.Email = token.Email
+	}
+
 	insert, err := helper.CreateManager(m)
+	if err == helper.ErrManagerExists {
+		helper.ReturnError(w, http.StatusConflict, err, "Create manager error")
+		return
+	}
 	if err != nil {
 		helper.ReturnError(w, http.StatusInternalServerError, err, "Create manager error")
 		return
@@ -163,7 +278,8 @@ func createManager(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(manager)
 }
 func getManagers(w http.ResponseWriter, r *http.Request) {
-	managers, err := helper.GetManagers()
+	caller, _ := helper.Caller(r)
+	managers, err := helper.GetLeagueManagers(caller.League)
 	if err != nil {
 		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
 		return
@@ -176,10 +292,11 @@ func getManager(w http.ResponseWriter, r *http.Request) {
 	manager := structs.Manager{}
 	managerID := mux.Vars(r)["id"]
 
+	caller, _ := helper.Caller(r)
 	if len(managerID) == 24 {
-		manager, err = helper.GetManagerByID(managerID)
+		manager, err = helper.LeagueManager(caller, managerID)
 		if err != nil {
-			helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
+			helper.ReturnError(w, http.StatusNotFound, err, constant.GETMANAGERERROR)
 			return
 		}
 	} else {
@@ -188,10 +305,31 @@ func getManager(w http.ResponseWriter, r *http.Request) {
 			helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
 			return
 		}
+		token, _ := helper.Token(r)
+		if manager.UserID != token.Subject && !helper.InLeague(caller, manager.League) {
+			helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("manager %s not found", managerID), constant.GETMANAGERERROR)
+			return
+		}
 	}
 
 	json.NewEncoder(w).Encode(manager)
 }
+func getManagerResults(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	manager, err := helper.LeagueManager(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	results, err := helper.GetManagerResults(manager.ID.Hex())
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETRESULTERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(results)
+}
 func managePlayers(w http.ResponseWriter, r *http.Request) {
 	var mp request.ManagePlayer
 
@@ -201,6 +339,18 @@ func managePlayers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// managers may release their own players, only admins hand players out
+	caller, _ := helper.Caller(r)
+	if mp.Type == 0 {
+		err = helper.AuthorizeSelf(caller, mp.Manager)
+	} else {
+		err = helper.AuthorizeRoles(caller, constant.ROLEADMIN)
+	}
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
 	manager, err := helper.GetManagerByID(mp.Manager)
 	if err != nil {
 		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
@@ -214,17 +364,18 @@ func managePlayers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if mp.Type == 0 {
-		manager.DeletePlayer(player)
+		manager, err = helper.RemoveManagerPlayer(manager, player.ID)
 	} else {
-		manager.AddPlayer(player)
+		manager, err = helper.AddManagerPlayer(manager, player)
+	}
+	if err == helper.ErrPlayerTaken {
+		helper.ReturnError(w, http.StatusConflict, err, constant.UPDATEERROR)
+		return
 	}
-
-	updateResult, err := helper.UpdateManager(&manager)
 	if err != nil {
 		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
 		return
 	}
-	fmt.Println("Updated Count", updateResult.ModifiedCount)
 
 	json.NewEncoder(w).Encode(manager)
 }
@@ -248,264 +399,2072 @@ func managePoints(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	manager.ManagePoint(mp.Point, mp.Type)
-
-	updateResult, err := helper.UpdateManager(&manager)
+	caller, _ := helper.Caller(r)
+	amount := mp.Point
+	if mp.Type == 0 {
+		amount = -mp.Point
+	}
+	manager, err = helper.ChangePoints(manager.ID, nil, nil, structs.LedgerEntry{
+		Amount: amount,
+		Source: constant.LEDGERADMIN,
+		Reason: mp.Reason,
+		Actor:  caller.ID.Hex(),
+	})
+	if err == helper.ErrInsufficientPoints {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("check your balance to precess"), "Balance error")
+		return
+	}
 	if err != nil {
 		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
 		return
 	}
-	fmt.Println("Updated Count", updateResult.ModifiedCount)
 
 	json.NewEncoder(w).Encode(manager)
 }
+func getLedger(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	err := helper.AuthorizeSelf(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
 
-// manager-end
-// redis-start-end
-func cleanRedis(w http.ResponseWriter, r *http.Request) {
-	err := helper.DeteleRedisKeys()
+	page, size, err := pageParams(r)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, "Delete redis error")
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.LEDGERERROR)
 		return
 	}
-	http.Error(w, "Redis data removed!!!", http.StatusOK)
+
+	entries, total, err := helper.GetLedger(mux.Vars(r)["id"], page, size)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.LEDGERERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(request.LedgerResponse{
+		Entries: entries,
+		Page:    page,
+		Size:    size,
+		Total:   total,
+	})
 }
 
-// season-start
-func createSeason(w http.ResponseWriter, r *http.Request) {
-	var s structs.Season
-	err := json.NewDecoder(r.Body).Decode(&s)
+func getPackOpenings(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	manager, err := helper.LeagueManager(caller, mux.Vars(r)["id"])
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETMANAGERERROR)
 		return
 	}
 
-	insert, err := helper.CreateSeason(s)
+	page, size, err := pageParams(r)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, "Create season error")
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.PACKHISTORYERROR)
 		return
 	}
 
-	season, err := helper.GetSeasonByID(insert.InsertedID.Hex())
+	openings, total, err := helper.GetPackOpenings(manager.ID.Hex(), page, size)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETSEASONERROR)
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.PACKHISTORYERROR)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(season)
+	json.NewEncoder(w).Encode(request.PackOpeningResponse{
+		Openings: openings,
+		Page:     page,
+		Size:     size,
+		Total:    total,
+	})
 }
-func changeStatus(w http.ResponseWriter, r *http.Request) {
-	var s request.SeasonRequest
-	err := json.NewDecoder(r.Body).Decode(&s)
+
+// pageParams reads the page and size query parameters of paged lists.
+func pageParams(r *http.Request) (int, int, error) {
+	page, size := 1, constant.LEDGERPAGESIZE
+	if value := r.URL.Query().Get("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return page, size, fmt.Errorf("page should be a positive number")
+		}
+		page = parsed
+	}
+	if value := r.URL.Query().Get("size"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > constant.LEDGERMAXPAGESIZE {
+			return page, size, fmt.Errorf("size should be between 1 and %d", constant.LEDGERMAXPAGESIZE)
+		}
+		size = parsed
+	}
+	return page, size, nil
+}
+func changeRole(w http.ResponseWriter, r *http.Request) {
+	var rr request.RoleRequest
+	err := json.NewDecoder(r.Body).Decode(&rr)
 	if err != nil {
 		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
 		return
 	}
 
-	season, err := helper.GetSeasonByID(s.ID)
+	found, _ := helper.IsExistInSlice(constant.ROLES, rr.Role)
+	if !found {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("unknown role %s", rr.Role), constant.ROLEERROR)
+		return
+	}
+
+	manager, err := helper.GetManagerByID(mux.Vars(r)["id"])
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETSEASONERROR)
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETMANAGERERROR)
 		return
 	}
 
-	season.ChangeStatus(s.IsActive)
-	_, err = helper.UpdateSeason(&season)
+	manager, err = helper.SetManagerRole(manager.ID, rr.Role)
 	if err != nil {
 		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(request.SeasonResponse{
-		ID:       season.ID.Hex(),
-		Title:    season.Title,
-		Type:     season.Type,
-		IsActive: season.IsActive,
-	})
+	json.NewEncoder(w).Encode(manager)
 }
-func getStanding(w http.ResponseWriter, r *http.Request) {
-	var sr request.StatisticRequest
-	err := json.NewDecoder(r.Body).Decode(&sr)
+
+// manager-end
+// league-start
+func createLeague(w http.ResponseWriter, r *http.Request) {
+	var lr request.LeagueRequest
+	err := json.NewDecoder(r.Body).Decode(&lr)
 	if err != nil {
 		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
 		return
 	}
 
-	season, err := helper.GetSeasonByID(sr.Season)
-	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETSEASONERROR)
+	if lr.Name == "" {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("league needs a name"), constant.LEAGUEERROR)
 		return
 	}
 
-	standing := helper.GetStanding(season.Results)
-	stats := helper.GetStats(season.Results)
+	caller, _ := helper.Caller(r)
+	if caller.League != "" {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("leave your current league before creating one"), constant.LEAGUEERROR)
+		return
+	}
 
-	json.NewEncoder(w).Encode(request.StatisticResponse{
-		Standing: standing,
-		Stats:    stats,
-	})
-}
-func getSeasons(w http.ResponseWriter, r *http.Request) {
-	seasons, err := helper.GetSeasons()
+	league, err := helper.CreateLeague(lr.Name, caller)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETSEASONERROR)
+		helper.ReturnError(w, http.StatusInternalServerError, err, "Create league error")
 		return
 	}
 
-	json.NewEncoder(w).Encode(seasons)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(league)
 }
-
-// season-end
-// result-start-end
-func resultLogic(w http.ResponseWriter, r *http.Request) {
-	var resultRequest request.ResultRequest
-	err := json.NewDecoder(r.Body).Decode(&resultRequest)
-	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+func getLeague(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	if caller.League == "" {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("manager is not in a league"), constant.LEAGUEERROR)
 		return
 	}
 
-	//getByID interface ver parametre refactor taski
-	//Concurrency her get icin 3 defa dbyi bekliyoz
-	homeManager, err := helper.GetManagerByID(resultRequest.Home)
+	league, err := helper.GetLeagueByID(caller.League)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.LEAGUEERROR)
 		return
 	}
 
-	awayManager, err := helper.GetManagerByID(resultRequest.Away)
+	managers, err := helper.GetLeagueManagers(league.ID.Hex())
 	if err != nil {
 		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
 		return
 	}
 
-	season, err := helper.GetSeasonByID(resultRequest.Season)
+	json.NewEncoder(w).Encode(request.LeagueResponse{
+		League:   league,
+		Managers: managers,
+	})
+}
+func joinLeague(w http.ResponseWriter, r *http.Request) {
+	var lr request.LeagueRequest
+	err := json.NewDecoder(r.Body).Decode(&lr)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETSEASONERROR)
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
 		return
 	}
 
-	//method yap refactor taski
-	homeScorers := &[]structs.Scorer{}
-	awayScorers := &[]structs.Scorer{}
-	for _, scorer := range resultRequest.Scorers {
-		if scorer.Manager == homeManager.ID.Hex() {
-			for _, player := range homeManager.Players {
-				if scorer.Player == player.ID.Hex() {
-					*homeScorers = append(*homeScorers, structs.Scorer{
-						Player: player,
-						Count:  scorer.Count,
-					})
-					break
-				}
-			}
-		} else {
-			for _, player := range awayManager.Players {
-				if scorer.Player == player.ID.Hex() {
-					*awayScorers = append(*awayScorers, structs.Scorer{
-						Player: player,
-						Count:  scorer.Count,
-					})
-					break
-				}
-			}
-		}
-	}
-
-	result := structs.Result{
-		Season:      resultRequest.Season,
-		Home:        resultRequest.Home,
-		Away:        resultRequest.Away,
-		SeasonType:  season.Type,
-		SeasonTitle: season.Title,
-		HomeManager: homeManager.Name,
-		AwayManager: awayManager.Name,
-		Score:       resultRequest.Score,
-		HomeScorers: *homeScorers,
-		AwayScorers: *awayScorers,
+	caller, _ := helper.Caller(r)
+	if caller.League != "" {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("leave your current league before joining another"), constant.LEAGUEERROR)
+		return
 	}
 
-	insert, err := helper.CreateResult(result)
+	league, err := helper.GetLeagueByCode(strings.ToUpper(strings.TrimSpace(lr.Code)))
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, "Season create error")
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("invite code is not valid"), constant.LEAGUEERROR)
 		return
 	}
-	result.ID = insert.InsertedID
 
-	homeManager.AddResult(result)
-	_, err = helper.UpdateManager(&homeManager)
+	manager, err := helper.SetManagerLeague(caller.ID, league.ID.Hex())
+	if err == helper.ErrPlayerTaken {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("a member of the league already owns one of your players"), constant.LEAGUEERROR)
+		return
+	}
 	if err != nil {
 		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
 		return
 	}
 
-	awayManager.AddResult(result)
-	_, err = helper.UpdateManager(&awayManager)
-	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+	json.NewEncoder(w).Encode(manager)
+}
+func leaveLeague(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	if caller.League == "" {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("manager is not in a league"), constant.LEAGUEERROR)
 		return
 	}
 
-	season.AddResult(result)
-	_, err = helper.UpdateSeason(&season)
+	league, err := helper.GetLeagueByID(caller.League)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.LEAGUEERROR)
+		return
+	}
+	if league.Owner == caller.ID.Hex() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("the owner can't leave the league"), constant.LEAGUEERROR)
 		return
 	}
 
-	json.NewEncoder(w).Encode(result)
-}
-
-// pack-start-end
-func packOpener(w http.ResponseWriter, r *http.Request) {
-	var pack request.Pack
-	err := json.NewDecoder(r.Body).Decode(&pack)
+	manager, err := helper.SetManagerLeague(caller.ID, "")
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
 		return
 	}
 
-	packPrice := constant.PACK_PRICES[pack.Type]
-	manager, err := helper.GetManagerByID(pack.Manager)
+	json.NewEncoder(w).Encode(manager)
+}
+func rotateInviteCode(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	league, err := helper.GetLeagueByID(caller.League)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
+		helper.ReturnError(w, http.StatusNotFound, err, constant.LEAGUEERROR)
 		return
 	}
 
-	if manager.Points < packPrice {
-		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("check manager point to process this action"), "Balance error")
+	if league.Owner != caller.ID.Hex() && !helper.HasRole(caller, constant.ROLEADMIN) {
+		helper.ReturnForbidden(w, fmt.Errorf("only the league owner can change the invite code"))
 		return
-	} else {
-		manager.ManagePoint(packPrice, 0)
 	}
 
-	filter, limit := helper.AddFilterViaType(pack.Type)
-	players, err := helper.SearchPlayerByFilter(filter, bson.D{}, int64(limit))
+	err = helper.RotateInviteCode(&league)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.SEARCHPLAYERERROR)
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
 		return
 	}
 
-	rand.Seed(time.Now().UnixNano())
-	random := helper.GetRandom(0, len(players))
-	player := players[random]
+	json.NewEncoder(w).Encode(league)
+}
+func getLeaguePackStats(w http.ResponseWriter, r *http.Request) {
+	days := constant.PACKSTATSDAYS
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("days should be a positive number"), constant.PACKHISTORYERROR)
+			return
+		}
+		days = parsed
+	}
 
-	manager.AddPlayer(player)
+	caller, _ := helper.Caller(r)
+	since := time.Now().AddDate(0, 0, -days)
+	pulls, stats, err := helper.GetLeaguePackStats(caller.League, since)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.PACKHISTORYERROR)
+		return
+	}
 
-	updateResult, err := helper.UpdateManager(&manager)
+	managers, err := helper.GetLeagueManagers(caller.League)
 	if err != nil {
-		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
 		return
 	}
-	fmt.Println("Manager players updated:", updateResult.ModifiedCount)
-	json.NewEncoder(w).Encode(request.PackResponse{
-		Player:  player,
-		Point:   manager.Points,
-		Message: fmt.Sprintf("%d Oyuncu arasindan %d. gelen oyuncu %s", len(players), random, player.Name),
+	names := make(map[string]string)
+	for _, manager := range managers {
+		names[manager.ID.Hex()] = manager.Name
+	}
+	for i := range stats {
+		stats[i].Name = names[stats[i].Manager]
+	}
+
+	json.NewEncoder(w).Encode(request.LeaguePackStatsResponse{
+		Since:    since,
+		Pulls:    pulls,
+		Managers: stats,
 	})
 }
 
+// league-end
+// redis-start-end
+func cleanRedis(w http.ResponseWriter, r *http.Request) {
+	err := helper.DeteleRedisKeys()
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, "Delete redis error")
+		return
+	}
+	http.Error(w, "Redis data removed!!!", http.StatusOK)
+}
+
+// season-start
+func createSeason(w http.ResponseWriter, r *http.Request) {
+	var s structs.Season
+	err := json.NewDecoder(r.Body).Decode(&s)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	err = helper.ValidateRules(s.Rules)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.RULESERROR)
+		return
+	}
+	if s.Meetings < 0 || s.Meetings > constant.MAXMEETINGS {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("meetings should be between 0 and %d", constant.MAXMEETINGS), constant.FIXTUREERROR)
+		return
+	}
+
+	if s.Status == "" {
+		s.Status = constant.STATUSDRAFT
+	}
+	if s.Status != constant.STATUSDRAFT && s.Status != constant.STATUSREGISTRATION && s.Status != constant.STATUSACTIVE {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("season can't be created as %s", s.Status), constant.STATUSERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	if caller.League == "" {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("join a league before creating seasons"), constant.LEAGUEERROR)
+		return
+	}
+	s.Owner = caller.ID.Hex()
+	s.League = caller.League
+
+	insert, err := helper.CreateSeason(s)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, "Create season error")
+		return
+	}
+
+	season, err := helper.GetSeasonByID(insert.InsertedID.Hex())
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETSEASONERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(season)
+}
+func changeStatus(w http.ResponseWriter, r *http.Request) {
+	var s request.SeasonRequest
+	err := json.NewDecoder(r.Body).Decode(&s)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, s.ID)
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	err = helper.AuthorizeSeason(caller, season)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	// clients which still send isActive only toggle between active and finished
+	status := s.Status
+	if status == "" {
+		status = constant.STATUSFINISHED
+		if s.IsActive {
+			status = constant.STATUSACTIVE
+		}
+	}
+
+	err = helper.TransitionSeason(&season, status)
+	if err != nil {
+		helper.ReturnError(w, http.StatusConflict, err, constant.STATUSERROR)
+		return
+	}
+
+	_, err = helper.UpdateSeason(&season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(request.SeasonResponse{
+		ID:       season.ID.Hex(),
+		Title:    season.Title,
+		Type:     season.Type,
+		IsActive: season.IsActive,
+		Status:   season.Status,
+	})
+}
+func getStanding(w http.ResponseWriter, r *http.Request) {
+	var sr request.StatisticRequest
+	err := json.NewDecoder(r.Body).Decode(&sr)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, sr.Season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	results, err := helper.GetSeasonResults(sr.Season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETRESULTERROR)
+		return
+	}
+
+	standing := []structs.Standing{}
+	groups := []structs.GroupStanding{}
+	if season.Type == constant.TOURNAMENT {
+		groups = helper.GetGroupStandings(season, results)
+	} else if season.Type != constant.CUP {
+		standing = helper.GetStanding(results, helper.SeasonRules(season))
+	}
+	stats, err := helper.GetSeasonStats(sr.Season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETRESULTERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(request.StatisticResponse{
+		Standing: standing,
+		Groups:   groups,
+		Stats:    stats,
+	})
+}
+func getSeasons(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	seasons, err := helper.GetSeasons(caller.League)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETSEASONERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(seasons)
+}
+func updateRules(w http.ResponseWriter, r *http.Request) {
+	var rules structs.Rules
+	err := json.NewDecoder(r.Body).Decode(&rules)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	err = helper.ValidateRules(&rules)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.RULESERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	err = helper.AuthorizeSeason(caller, season)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	if season.Locked() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season is %s", season.Status), constant.STATUSERROR)
+		return
+	}
+
+	season.Rules = &rules
+	_, err = helper.UpdateSeason(&season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(season.Rules)
+}
+func createFixtures(w http.ResponseWriter, r *http.Request) {
+	var fr request.FixtureRequest
+	err := json.NewDecoder(r.Body).Decode(&fr)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	err = helper.AuthorizeSeason(caller, season)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	if season.Locked() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season is %s", season.Status), constant.STATUSERROR)
+		return
+	}
+
+	if season.Type == constant.CUP || season.Type == constant.TOURNAMENT {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("%s fixtures are created by the draw", season.Type), constant.FIXTUREERROR)
+		return
+	}
+	if season.HasPlayedFixture() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season already has played fixtures"), constant.FIXTUREERROR)
+		return
+	}
+	ids, err := season.Entrants(fr.Managers)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.FIXTUREERROR)
+		return
+	}
+	if len(ids) < 2 {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("at least two managers needed to generate fixtures"), constant.FIXTUREERROR)
+		return
+	}
+
+	managers, err := helper.LeagueManagers(season.League, ids)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	season.Fixtures = helper.GenerateFixtures(managers, fr.Double, fr.Shuffle)
+	_, err = helper.UpdateSeason(&season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(season.Fixtures)
+}
+func getFixtures(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(season.Fixtures)
+}
+func createBracket(w http.ResponseWriter, r *http.Request) {
+	var br request.BracketRequest
+	err := json.NewDecoder(r.Body).Decode(&br)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	err = helper.AuthorizeSeason(caller, season)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	if season.Locked() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season is %s", season.Status), constant.STATUSERROR)
+		return
+	}
+
+	if season.Type != constant.CUP {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("brackets can only be drawn for cup seasons"), constant.BRACKETERROR)
+		return
+	}
+	if season.HasPlayedFixture() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season already has played fixtures"), constant.BRACKETERROR)
+		return
+	}
+
+	ids, err := season.Entrants(br.Managers)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.BRACKETERROR)
+		return
+	}
+
+	managers, err := helper.LeagueManagers(season.League, ids)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	season.Fixtures = []structs.Fixture{}
+	err = helper.GenerateBracket(&season, managers, br.Seeded, br.TwoLegged)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.BRACKETERROR)
+		return
+	}
+
+	_, err = helper.UpdateSeason(&season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(helper.BracketResponse(season))
+}
+func getBracket(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	if season.Type != constant.CUP && season.Type != constant.TOURNAMENT {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("season has no knockout stage"), constant.BRACKETERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(helper.BracketResponse(season))
+}
+func createGroups(w http.ResponseWriter, r *http.Request) {
+	var gr request.GroupRequest
+	err := json.NewDecoder(r.Body).Decode(&gr)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	err = helper.AuthorizeSeason(caller, season)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	if season.Locked() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season is %s", season.Status), constant.STATUSERROR)
+		return
+	}
+
+	if season.Type != constant.TOURNAMENT {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("groups can only be drawn for tournament seasons"), constant.GROUPERROR)
+		return
+	}
+	if season.HasPlayedFixture() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season already has played fixtures"), constant.GROUPERROR)
+		return
+	}
+
+	ids, err := season.Entrants(gr.Managers)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.GROUPERROR)
+		return
+	}
+
+	managers, err := helper.LeagueManagers(season.League, ids)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	season.Qualifiers = gr.Qualifiers
+	season.TwoLegged = gr.TwoLegged
+	err = helper.DrawGroups(&season, managers, gr.Groups, gr.Seeded, gr.Double)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.GROUPERROR)
+		return
+	}
+
+	_, err = helper.UpdateSeason(&season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(season.Groups)
+}
+func assignClubs(w http.ResponseWriter, r *http.Request) {
+	var cr request.ClubRequest
+	err := json.NewDecoder(r.Body).Decode(&cr)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	err = helper.AuthorizeSeason(caller, season)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	if season.Locked() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season is %s", season.Status), constant.STATUSERROR)
+		return
+	}
+	if cr.MaxOverall > 0 && cr.MinOverall > cr.MaxOverall {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("minimum overall is above the maximum"), constant.CLUBERROR)
+		return
+	}
+
+	ids, err := season.Entrants(cr.Managers)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.CLUBERROR)
+		return
+	}
+
+	managers, err := helper.LeagueManagers(season.League, ids)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	round, err := helper.AssignClubs(&season, managers, cr)
+	if err == helper.ErrNoFreshClubs {
+		helper.ReturnError(w, http.StatusConflict, err, constant.CLUBERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.CLUBERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(round)
+}
+func joinSeason(w http.ResponseWriter, r *http.Request) {
+	var pr request.ParticipantRequest
+	err := json.NewDecoder(r.Body).Decode(&pr)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	if helper.AuthorizeSelf(caller, pr.Manager) != nil {
+		err = helper.AuthorizeSeason(caller, season)
+		if err != nil {
+			helper.ReturnForbidden(w, err)
+			return
+		}
+	}
+
+	if season.Status != constant.STATUSREGISTRATION {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season is not open for registration"), constant.REGISTRATIONERROR)
+		return
+	}
+
+	manager, err := helper.LeagueManager(caller, pr.Manager)
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	found, _ := season.IsParticipant(manager.ID.Hex())
+	if found {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("%s already joined the season", manager.Name), constant.REGISTRATIONERROR)
+		return
+	}
+
+	season.Join(manager.ID.Hex())
+	_, err = helper.UpdateSeason(&season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(season.Participants)
+}
+func leaveSeason(w http.ResponseWriter, r *http.Request) {
+	var pr request.ParticipantRequest
+	err := json.NewDecoder(r.Body).Decode(&pr)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	if helper.AuthorizeSelf(caller, pr.Manager) != nil {
+		err = helper.AuthorizeSeason(caller, season)
+		if err != nil {
+			helper.ReturnForbidden(w, err)
+			return
+		}
+	}
+
+	if season.Status != constant.STATUSREGISTRATION {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season is not open for registration"), constant.REGISTRATIONERROR)
+		return
+	}
+
+	found, _ := season.IsParticipant(pr.Manager)
+	if !found {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("manager %s is not registered for the season", pr.Manager), constant.REGISTRATIONERROR)
+		return
+	}
+
+	season.Leave(pr.Manager)
+	_, err = helper.UpdateSeason(&season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(season.Participants)
+}
+
+// season-end
+// draft-start
+func createDraft(w http.ResponseWriter, r *http.Request) {
+	var dr request.DraftRequest
+	err := json.NewDecoder(r.Body).Decode(&dr)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	err = helper.AuthorizeSeason(caller, season)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	if season.Locked() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season is %s", season.Status), constant.STATUSERROR)
+		return
+	}
+	if season.Draft != "" {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season already has a draft"), constant.DRAFTERROR)
+		return
+	}
+	if dr.Order != "" && dr.Order != constant.DRAFTRANDOM && dr.Order != constant.DRAFTSTANDINGS {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("draft order should be %s or %s", constant.DRAFTRANDOM, constant.DRAFTSTANDINGS), constant.DRAFTERROR)
+		return
+	}
+	if dr.Mode != "" && dr.Mode != constant.DRAFTSNAKE && dr.Mode != constant.DRAFTLINEAR {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("draft mode should be %s or %s", constant.DRAFTSNAKE, constant.DRAFTLINEAR), constant.DRAFTERROR)
+		return
+	}
+	if dr.PickSeconds < 0 {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("pick clock can't be negative"), constant.DRAFTERROR)
+		return
+	}
+	for _, position := range dr.Positions {
+		if strings.TrimSpace(position) == "" {
+			helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("draft positions can't be empty"), constant.DRAFTERROR)
+			return
+		}
+	}
+
+	ids, err := season.Entrants(dr.Managers)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.DRAFTERROR)
+		return
+	}
+	if len(ids) == 0 {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("draft needs managers"), constant.DRAFTERROR)
+		return
+	}
+
+	_, err = helper.LeagueManagers(season.League, ids)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	standings := []structs.Standing{}
+	if dr.Order == constant.DRAFTSTANDINGS {
+		standingsSeason := season
+		if dr.StandingsSeason != "" {
+			standingsSeason, err = helper.LeagueSeason(caller, dr.StandingsSeason)
+			if err != nil {
+				helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+				return
+			}
+		}
+		standings = standingsSeason.FinalStanding
+		if len(standings) == 0 {
+			results, err := helper.GetSeasonResults(standingsSeason.ID.Hex())
+			if err != nil {
+				helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETRESULTERROR)
+				return
+			}
+			standings = helper.GetStanding(results, helper.SeasonRules(standingsSeason))
+		}
+	}
+
+	draft, err := helper.CreateDraft(&season, ids, dr, standings)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DRAFTERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(draftBoard(draft))
+}
+func getDraft(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	draft, err := helper.GetDraftByID(mux.Vars(r)["id"])
+	if err != nil || !helper.InLeague(caller, draft.League) {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("draft %s not found", mux.Vars(r)["id"]), constant.DRAFTERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(draftBoard(draft))
+}
+func draftPlayer(w http.ResponseWriter, r *http.Request) {
+	var dp request.DraftPickRequest
+	err := json.NewDecoder(r.Body).Decode(&dp)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	draft, err := helper.GetDraftByID(mux.Vars(r)["id"])
+	if err != nil || !helper.InLeague(caller, draft.League) {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("draft %s not found", mux.Vars(r)["id"]), constant.DRAFTERROR)
+		return
+	}
+
+	player, err := helper.GetPlayerByID(dp.Player)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETPLAYERERROR)
+		return
+	}
+
+	_, err = helper.MakePick(&draft, caller.ID.Hex(), player, false)
+	if err == helper.ErrNotOnTheClock {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+	if err == helper.ErrPlayerTaken || err == helper.ErrPickConflict {
+		helper.ReturnError(w, http.StatusConflict, err, constant.DRAFTERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.DRAFTERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(draftBoard(draft))
+}
+
+// draftBoard adds whose turn it is to a draft.
+func draftBoard(draft structs.Draft) request.DraftBoard {
+	board := request.DraftBoard{Draft: draft}
+	manager, round, open := draft.OnTheClock()
+	if open {
+		board.OnTheClock = manager
+		board.Round = round
+		board.OpenSlots = draft.OpenSlots(manager)
+	}
+	return board
+}
+
+// draft-end
+
+// market-start
+func createListing(w http.ResponseWriter, r *http.Request) {
+	var lr request.ListingRequest
+	err := json.NewDecoder(r.Body).Decode(&lr)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	err = helper.AuthorizeSelf(caller, lr.Manager)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	if lr.StartPrice <= 0 {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("start price should be positive"), constant.MARKETERROR)
+		return
+	}
+	if lr.BuyNow != 0 && lr.BuyNow < lr.StartPrice {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("buy now price can't be below the start price"), constant.MARKETERROR)
+		return
+	}
+	if lr.Hours == 0 {
+		lr.Hours = constant.MARKETHOURS
+	}
+	if lr.Hours < 1 || lr.Hours > constant.MARKETMAXHOURS {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("auction should run between 1 and %d hours", constant.MARKETMAXHOURS), constant.MARKETERROR)
+		return
+	}
+
+	manager, err := helper.GetManagerByID(lr.Manager)
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETMANAGERERROR)
+		return
+	}
+	found, player := manager.OwnedPlayer(lr.Player)
+	if !found {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("%s doesn't own player %s", manager.Name, lr.Player), constant.MARKETERROR)
+		return
+	}
+
+	listing, err := helper.CreateListing(manager, player, lr.StartPrice, lr.BuyNow, lr.Hours)
+	if err == helper.ErrAlreadyListed {
+		helper.ReturnError(w, http.StatusConflict, err, constant.MARKETERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.MARKETERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(listing)
+}
+func getListings(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	listings, err := helper.GetListings(caller.League)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.MARKETERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(listings)
+}
+func getListing(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	listing, err := helper.GetListingByID(mux.Vars(r)["id"])
+	if err != nil || !helper.InLeague(caller, listing.League) {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("listing %s not found", mux.Vars(r)["id"]), constant.MARKETERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(listing)
+}
+func placeBid(w http.ResponseWriter, r *http.Request) {
+	var br request.BidRequest
+	err := json.NewDecoder(r.Body).Decode(&br)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+	bid(w, r, br.Amount)
+}
+func buyNow(w http.ResponseWriter, r *http.Request) {
+	listing, err := helper.GetListingByID(mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("listing %s not found", mux.Vars(r)["id"]), constant.MARKETERROR)
+		return
+	}
+	if listing.BuyNow == 0 {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("%s has no buy now price", listing.Player.Name), constant.MARKETERROR)
+		return
+	}
+	bid(w, r, listing.BuyNow)
+}
+
+// bid places a bid of the caller on the listing of the request.
+func bid(w http.ResponseWriter, r *http.Request, amount int) {
+	caller, _ := helper.Caller(r)
+	listing, err := helper.GetListingByID(mux.Vars(r)["id"])
+	if err != nil || caller.League != listing.League {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("listing %s not found", mux.Vars(r)["id"]), constant.MARKETERROR)
+		return
+	}
+	if listing.Seller == caller.ID.Hex() {
+		helper.ReturnForbidden(w, fmt.Errorf("managers can't bid on their own players"))
+		return
+	}
+	if caller.Owns(listing.Player.ID) {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("you already own %s", listing.Player.Name), constant.MARKETERROR)
+		return
+	}
+	if listing.Status != constant.LISTINGOPEN || !listing.EndsAt.After(time.Now()) {
+		helper.ReturnError(w, http.StatusConflict, helper.ErrListingClosed, constant.MARKETERROR)
+		return
+	}
+
+	err = helper.PlaceBid(&listing, caller, amount)
+	if err == helper.ErrInsufficientPoints {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("check your balance to precess"), "Balance error")
+		return
+	}
+	if err == helper.ErrBidTooLow || err == helper.ErrListingClosed {
+		helper.ReturnError(w, http.StatusConflict, err, constant.MARKETERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.MARKETERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(listing)
+}
+func cancelListing(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	listing, err := helper.GetListingByID(mux.Vars(r)["id"])
+	if err != nil || !helper.InLeague(caller, listing.League) {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("listing %s not found", mux.Vars(r)["id"]), constant.MARKETERROR)
+		return
+	}
+
+	err = helper.AuthorizeSelf(caller, listing.Seller)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+	if listing.HighBidder != "" {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("auctions with bids can't be cancelled"), constant.MARKETERROR)
+		return
+	}
+
+	err = helper.CancelListing(&listing)
+	if err == helper.ErrListingClosed {
+		helper.ReturnError(w, http.StatusConflict, err, constant.MARKETERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.MARKETERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(listing)
+}
+
+// market-end
+
+// trade-start
+func createTrade(w http.ResponseWriter, r *http.Request) {
+	var tr request.TradeRequest
+	err := json.NewDecoder(r.Body).Decode(&tr)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	err = helper.AuthorizeSelf(caller, tr.Manager)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	from, err := helper.GetManagerByID(tr.Manager)
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETMANAGERERROR)
+		return
+	}
+	to, err := helper.GetManagerByID(tr.To)
+	if err != nil || to.League != from.League {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("manager %s not found", tr.To), constant.GETMANAGERERROR)
+		return
+	}
+	if from.ID == to.ID {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("managers can't trade with themselves"), constant.TRADEERROR)
+		return
+	}
+
+	trade, status, err := proposeTrade(from, to, tr, nil)
+	if err != nil {
+		helper.ReturnError(w, status, err, constant.TRADEERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(trade)
+}
+
+// proposeTrade validates both sides of the request and stores the trade, it
+// returns the status code to answer with when that fails.
+func proposeTrade(from, to structs.Manager, tr request.TradeRequest, counterOf *structs.Trade) (structs.Trade, int, error) {
+	if len(tr.Offered.Players) == 0 && tr.Offered.Points == 0 && len(tr.Requested.Players) == 0 && tr.Requested.Points == 0 {
+		return structs.Trade{}, http.StatusBadRequest, fmt.Errorf("trade offers nothing")
+	}
+	offered, err := helper.OfferSide(from, tr.Offered)
+	if err != nil {
+		return structs.Trade{}, http.StatusBadRequest, err
+	}
+	requested, err := helper.OfferSide(to, tr.Requested)
+	if err != nil {
+		return structs.Trade{}, http.StatusBadRequest, err
+	}
+
+	trade, err := helper.CreateTrade(from, to, offered, requested, counterOf)
+	if err == helper.ErrTradeClosed {
+		return trade, http.StatusConflict, err
+	}
+	if err != nil {
+		return trade, http.StatusInternalServerError, err
+	}
+	return trade, http.StatusCreated, nil
+}
+func getTrades(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	trades, err := helper.GetTrades(caller.ID.Hex(), r.URL.Query().Get("status"))
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.TRADEERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(trades)
+}
+func getTrade(w http.ResponseWriter, r *http.Request) {
+	trade, _, found := partyTrade(w, r)
+	if !found {
+		return
+	}
+
+	json.NewEncoder(w).Encode(trade)
+}
+
+// partyTrade loads the trade of the request when the caller is part of it,
+// admins see every trade.
+func partyTrade(w http.ResponseWriter, r *http.Request) (structs.Trade, structs.Manager, bool) {
+	caller, _ := helper.Caller(r)
+	trade, err := helper.GetTradeByID(mux.Vars(r)["id"])
+	if err != nil || (helper.AuthorizeSelf(caller, trade.From) != nil && helper.AuthorizeSelf(caller, trade.To) != nil) {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("trade %s not found", mux.Vars(r)["id"]), constant.TRADEERROR)
+		return trade, caller, false
+	}
+	return trade, caller, true
+}
+func acceptTrade(w http.ResponseWriter, r *http.Request) {
+	trade, caller, found := partyTrade(w, r)
+	if !found {
+		return
+	}
+	err := helper.AuthorizeSelf(caller, trade.To)
+	if err != nil {
+		helper.ReturnForbidden(w, fmt.Errorf("only %s can accept the trade", trade.ToName))
+		return
+	}
+	if trade.Status != constant.TRADEPENDING {
+		helper.ReturnError(w, http.StatusConflict, helper.ErrTradeClosed, constant.TRADEERROR)
+		return
+	}
+
+	managers, err := helper.GetManagersByIDs([]string{trade.From, trade.To})
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
+		return
+	}
+	from, to := managers[0], managers[1]
+	for i, side := range []structs.TradeSide{trade.Offered, trade.Requested} {
+		err = helper.CheckSide(managers[i], side)
+		if err != nil {
+			helper.ReturnError(w, http.StatusConflict, err, constant.TRADEERROR)
+			return
+		}
+	}
+
+	err = helper.AcceptTrade(&trade, from, to)
+	if err == helper.ErrTradeClosed || err == helper.ErrTradeChanged {
+		helper.ReturnError(w, http.StatusConflict, err, constant.TRADEERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(trade)
+}
+func rejectTrade(w http.ResponseWriter, r *http.Request) {
+	trade, caller, found := partyTrade(w, r)
+	if !found {
+		return
+	}
+	err := helper.AuthorizeSelf(caller, trade.To)
+	if err != nil {
+		helper.ReturnForbidden(w, fmt.Errorf("only %s can reject the trade", trade.ToName))
+		return
+	}
+	closeTrade(w, trade, constant.TRADEREJECTED)
+}
+func cancelTrade(w http.ResponseWriter, r *http.Request) {
+	trade, caller, found := partyTrade(w, r)
+	if !found {
+		return
+	}
+	err := helper.AuthorizeSelf(caller, trade.From)
+	if err != nil {
+		helper.ReturnForbidden(w, fmt.Errorf("only %s can cancel the trade", trade.FromName))
+		return
+	}
+	closeTrade(w, trade, constant.TRADECANCELLED)
+}
+func closeTrade(w http.ResponseWriter, trade structs.Trade, status string) {
+	err := helper.CloseTrade(&trade, status)
+	if err == helper.ErrTradeClosed {
+		helper.ReturnError(w, http.StatusConflict, err, constant.TRADEERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(trade)
+}
+func counterTrade(w http.ResponseWriter, r *http.Request) {
+	var tr request.TradeRequest
+	err := json.NewDecoder(r.Body).Decode(&tr)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	trade, caller, found := partyTrade(w, r)
+	if !found {
+		return
+	}
+	err = helper.AuthorizeSelf(caller, trade.To)
+	if err != nil {
+		helper.ReturnForbidden(w, fmt.Errorf("only %s can counter the trade", trade.ToName))
+		return
+	}
+	if trade.Status != constant.TRADEPENDING {
+		helper.ReturnError(w, http.StatusConflict, helper.ErrTradeClosed, constant.TRADEERROR)
+		return
+	}
+
+	// the counter goes the other way round
+	managers, err := helper.GetManagersByIDs([]string{trade.To, trade.From})
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
+		return
+	}
+	counter, status, err := proposeTrade(managers[0], managers[1], tr, &trade)
+	if err != nil {
+		helper.ReturnError(w, status, err, constant.TRADEERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(counter)
+}
+
+// trade-end
+// result-start-end
+func resultLogic(w http.ResponseWriter, r *http.Request) {
+	var resultRequest request.ResultRequest
+	err := json.NewDecoder(r.Body).Decode(&resultRequest)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	if resultRequest.Home == resultRequest.Away {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("a manager can't play against themselves"), constant.RESULTERROR)
+		return
+	}
+	if len(resultRequest.Score) != 2 {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("score should contain home and away goals"), constant.RESULTERROR)
+		return
+	}
+
+	//getByID interface ver parametre refactor taski
+	//Concurrency her get icin 3 defa dbyi bekliyoz
+	caller, _ := helper.Caller(r)
+	homeManager, err := helper.LeagueManager(caller, resultRequest.Home)
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	awayManager, err := helper.LeagueManager(caller, resultRequest.Away)
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	season, err := helper.LeagueSeason(caller, resultRequest.Season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	if caller.ID != homeManager.ID && caller.ID != awayManager.ID {
+		err = helper.AuthorizeSeason(caller, season)
+		if err != nil {
+			helper.ReturnForbidden(w, err)
+			return
+		}
+	}
+
+	if season.Status != constant.STATUSACTIVE {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("results can only be recorded for active seasons, season is %s", season.Status), constant.STATUSERROR)
+		return
+	}
+
+	for _, manager := range []structs.Manager{homeManager, awayManager} {
+		if !season.Registered(manager.ID.Hex()) {
+			helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("%s is not registered for the season", manager.Name), constant.RESULTERROR)
+			return
+		}
+	}
+
+	scheduled, played := season.PairMeetings(resultRequest.Home, resultRequest.Away)
+	if len(season.Fixtures) == 0 && season.Meetings > 0 {
+		scheduled = season.Meetings
+		played, err = helper.CountMeetings(resultRequest.Season, resultRequest.Home, resultRequest.Away)
+		if err != nil {
+			helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETRESULTERROR)
+			return
+		}
+	}
+	if (len(season.Fixtures) > 0 || season.Meetings > 0) && played >= scheduled {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("%s and %s already played all %d scheduled meetings", homeManager.Name, awayManager.Name, scheduled), constant.RESULTERROR)
+		return
+	}
+
+	fixtureFound, fixtureIndex := season.PendingFixture(resultRequest.Fixture, resultRequest.Home, resultRequest.Away)
+	if len(season.Fixtures) > 0 && !fixtureFound {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("no pending fixture for %s - %s", homeManager.Name, awayManager.Name), constant.FIXTUREERROR)
+		return
+	}
+	if fixtureFound {
+		err = helper.ValidateTieResult(&season, fixtureIndex, resultRequest.Score, resultRequest.Penalties)
+		if err != nil {
+			helper.ReturnError(w, http.StatusBadRequest, err, constant.BRACKETERROR)
+			return
+		}
+	}
+
+	homeScorers, awayScorers := helper.GetScorers(resultRequest.Scorers, homeManager, awayManager)
+
+	result := structs.Result{
+		Season:      resultRequest.Season,
+		Home:        resultRequest.Home,
+		Away:        resultRequest.Away,
+		SeasonType:  season.Type,
+		SeasonTitle: season.Title,
+		HomeManager: homeManager.Name,
+		AwayManager: awayManager.Name,
+		Score:       resultRequest.Score,
+		ExtraTime:   resultRequest.ExtraTime,
+		Penalties:   resultRequest.Penalties,
+		YellowCards: resultRequest.Yellow,
+		RedCards:    resultRequest.Red,
+		HomeScorers: homeScorers,
+		AwayScorers: awayScorers,
+	}
+	if fixtureFound {
+		result.Fixture = season.Fixtures[fixtureIndex].ID.Hex()
+		result.Matchday = season.Fixtures[fixtureIndex].Matchday
+		result.Group = season.Fixtures[fixtureIndex].Group
+	}
+	result.HomeClub = season.ClubFor(result.Home, result.Matchday)
+	result.AwayClub = season.ClubFor(result.Away, result.Matchday)
+
+	result.ID = primitive.NewObjectID()
+	if fixtureFound {
+		season.PlayFixture(fixtureIndex, result)
+		helper.AdvanceBracket(&season, fixtureIndex)
+		results, err := helper.GetSeasonResults(season.ID.Hex())
+		if err != nil {
+			helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETRESULTERROR)
+			return
+		}
+		_, err = helper.CompleteGroupStage(&season, append(results, result))
+		if err != nil {
+			helper.ReturnError(w, http.StatusInternalServerError, err, constant.BRACKETERROR)
+			return
+		}
+	}
+
+	err = helper.RecordResult(result, &season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, "Season create error")
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+func updateResult(w http.ResponseWriter, r *http.Request) {
+	var resultRequest request.ResultRequest
+	err := json.NewDecoder(r.Body).Decode(&resultRequest)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	if len(resultRequest.Score) != 2 {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("score should contain home and away goals"), constant.RESULTERROR)
+		return
+	}
+
+	before, err := helper.GetResultByID(mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETRESULTERROR)
+		return
+	}
+
+	homeManager, err := helper.GetManagerByID(before.Home)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	awayManager, err := helper.GetManagerByID(before.Away)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, before.Season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	err = helper.AuthorizeSeason(caller, season)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	if season.Locked() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season is %s", season.Status), constant.STATUSERROR)
+		return
+	}
+
+	after := before
+	after.Score = resultRequest.Score
+	after.ExtraTime = resultRequest.ExtraTime
+	after.Penalties = resultRequest.Penalties
+	after.YellowCards = resultRequest.Yellow
+	after.RedCards = resultRequest.Red
+	after.HomeScorers, after.AwayScorers = helper.GetScorers(resultRequest.Scorers, homeManager, awayManager)
+
+	fixtureFound, fixtureIndex := season.FixtureByID(before.Fixture)
+	if fixtureFound {
+		err = helper.RollbackFixture(&season, fixtureIndex)
+		if err != nil {
+			helper.ReturnError(w, http.StatusConflict, err, constant.BRACKETERROR)
+			return
+		}
+		_, fixtureIndex = season.FixtureByID(before.Fixture)
+
+		err = helper.ValidateTieResult(&season, fixtureIndex, after.Score, after.Penalties)
+		if err != nil {
+			helper.ReturnError(w, http.StatusBadRequest, err, constant.BRACKETERROR)
+			return
+		}
+	}
+
+	if fixtureFound {
+		season.PlayFixture(fixtureIndex, after)
+		helper.AdvanceBracket(&season, fixtureIndex)
+		results, err := helper.GetSeasonResults(season.ID.Hex())
+		if err != nil {
+			helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETRESULTERROR)
+			return
+		}
+		for i := range results {
+			if results[i].ID == after.ID {
+				results[i] = after
+			}
+		}
+		_, err = helper.CompleteGroupStage(&season, results)
+		if err != nil {
+			helper.ReturnError(w, http.StatusInternalServerError, err, constant.BRACKETERROR)
+			return
+		}
+	}
+
+	err = helper.ReviseResult(after, &season, structs.ResultAudit{
+		Result: after.ID.Hex(),
+		Season: after.Season,
+		Action: constant.AUDITUPDATE,
+		Actor:  caller.ID.Hex(),
+		Before: &before,
+		After:  &after,
+		At:     time.Now(),
+	})
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(after)
+}
+func deleteResult(w http.ResponseWriter, r *http.Request) {
+	before, err := helper.GetResultByID(mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETRESULTERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	season, err := helper.LeagueSeason(caller, before.Season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETSEASONERROR)
+		return
+	}
+
+	err = helper.AuthorizeSeason(caller, season)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	if season.Locked() {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("season is %s", season.Status), constant.STATUSERROR)
+		return
+	}
+
+	fixtureFound, fixtureIndex := season.FixtureByID(before.Fixture)
+	if fixtureFound {
+		err = helper.RollbackFixture(&season, fixtureIndex)
+		if err != nil {
+			helper.ReturnError(w, http.StatusConflict, err, constant.BRACKETERROR)
+			return
+		}
+		_, fixtureIndex = season.FixtureByID(before.Fixture)
+	}
+
+	if fixtureFound {
+		season.UnplayFixture(fixtureIndex)
+	}
+
+	err = helper.RemoveResult(before.ID, &season, structs.ResultAudit{
+		Result: before.ID.Hex(),
+		Season: before.Season,
+		Action: constant.AUDITDELETE,
+		Actor:  caller.ID.Hex(),
+		Before: &before,
+		At:     time.Now(),
+	})
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(before)
+}
+func getResultAudits(w http.ResponseWriter, r *http.Request) {
+	result, err := helper.GetResultByID(mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETRESULTERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	_, err = helper.LeagueSeason(caller, result.Season)
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETRESULTERROR)
+		return
+	}
+
+	audits, err := helper.GetResultAudits(result.ID.Hex())
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.AUDITERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(audits)
+}
+
+// pack-start-end
+func packOpener(w http.ResponseWriter, r *http.Request) {
+	var pack request.Pack
+	err := json.NewDecoder(r.Body).Decode(&pack)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	err = helper.AuthorizeSelf(caller, pack.Manager)
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	definition, err := helper.GetPack(pack.Pack, pack.Type)
+	if err != nil || !definition.OnSale {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("pack is not on sale"), constant.PACKERROR)
+		return
+	}
+
+	manager, err := helper.GetManagerByID(pack.Manager)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	if manager.Points < definition.Price {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("check manager point to process this action"), "Balance error")
+		return
+	}
+
+	draw, rng, err := helper.NextDraw(constant.DRAWPACK, pack.ClientSeed)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DRAWERROR)
+		return
+	}
+	draw.Manager = manager.ID.Hex()
+	draw.Pack = definition.Key
+	taken, err := helper.TakenInLeague(manager.League, manager.ID.Hex())
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.SEARCHPLAYERERROR)
+		return
+	}
+	players, err := helper.DrawPack(definition, taken, &draw, rng)
+	if err == helper.ErrPoolChanged {
+		helper.ReturnError(w, http.StatusConflict, err, constant.SEARCHPLAYERERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.SEARCHPLAYERERROR)
+		return
+	}
+	quickSell, err := helper.GetQuickSell()
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.QUICKSELLERROR)
+		return
+	}
+
+	names := []string{}
+	fresh := []structs.Player{}
+	duplicates := []structs.Player{}
+	duplicateNames := []string{}
+	quickSold := 0
+	for _, player := range players {
+		names = append(names, player.Name)
+		if manager.Owns(player.ID) {
+			duplicates = append(duplicates, player)
+			duplicateNames = append(duplicateNames, player.Name)
+			if quickSell.Duplicates {
+				quickSold += helper.QuickSellValue(quickSell, player)
+			}
+		} else {
+			fresh = append(fresh, player)
+		}
+	}
+
+	entries := []structs.LedgerEntry{{
+		Amount: -definition.Price,
+		Source: constant.LEDGERPACK,
+		Reason: fmt.Sprintf("%s: %s", definition.Name, strings.Join(names, ", ")),
+		Actor:  manager.ID.Hex(),
+	}}
+	if quickSold > 0 {
+		entries = append(entries, structs.LedgerEntry{
+			Amount: quickSold,
+			Source: constant.LEDGERQUICKSELL,
+			Reason: fmt.Sprintf("duplicates from %s: %s", definition.Name, strings.Join(duplicateNames, ", ")),
+			Actor:  manager.ID.Hex(),
+		})
+	}
+	manager, err = helper.OpenPack(manager.ID, bson.M{"$push": bson.M{"players": bson.M{"$each": fresh}}}, &structs.PackOpening{
+		League:     manager.League,
+		Pack:       definition.Key,
+		Name:       definition.Name,
+		Price:      definition.Price,
+		Players:    players,
+		Duplicates: duplicates,
+		QuickSold:  quickSold,
+	}, &draw, entries...)
+	if err == helper.ErrInsufficientPoints {
+		helper.ReturnError(w, http.StatusBadRequest, err, "Balance error")
+		return
+	}
+	if err == helper.ErrPlayerTaken {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("a pulled player was taken in the league meanwhile, open the pack again"), constant.PACKERROR)
+		return
+	}
+	if err == mongo.ErrNoDocuments {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("your roster changed meanwhile, open the pack again"), constant.PACKERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+	json.NewEncoder(w).Encode(request.PackResponse{
+		Player:     players[0],
+		Players:    players,
+		Duplicates: duplicates,
+		QuickSold:  quickSold,
+		Draw:       draw.ID.Hex(),
+		Point:      manager.Points,
+		Message:    fmt.Sprintf("%s paketinden gelen oyuncular: %s", definition.Name, strings.Join(names, ", ")),
+	})
+}
+func quickSellPlayers(w http.ResponseWriter, r *http.Request) {
+	var qs request.QuickSellRequest
+	err := json.NewDecoder(r.Body).Decode(&qs)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	caller, _ := helper.Caller(r)
+	err = helper.AuthorizeSelf(caller, mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnForbidden(w, err)
+		return
+	}
+
+	if len(qs.Players) == 0 {
+		helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("no players to quick sell"), constant.QUICKSELLERROR)
+		return
+	}
+
+	manager, err := helper.GetManagerByID(mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.GETMANAGERERROR)
+		return
+	}
+
+	quickSell, err := helper.GetQuickSell()
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.QUICKSELLERROR)
+		return
+	}
+
+	listed, err := helper.ListedPlayers(manager.ID.Hex())
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.MARKETERROR)
+		return
+	}
+
+	ids := []primitive.ObjectID{}
+	names := []string{}
+	value := 0
+	seen := make(map[string]bool)
+	for _, id := range qs.Players {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		found, player := manager.OwnedPlayer(id)
+		if !found {
+			helper.ReturnError(w, http.StatusBadRequest, fmt.Errorf("%s doesn't own player %s", manager.Name, id), constant.QUICKSELLERROR)
+			return
+		}
+		if listed[player.ID.Hex()] {
+			helper.ReturnError(w, http.StatusConflict, fmt.Errorf("%s is on the transfer market", player.Name), constant.QUICKSELLERROR)
+			return
+		}
+		ids = append(ids, player.ID)
+		names = append(names, player.Name)
+		value += helper.QuickSellValue(quickSell, player)
+	}
+
+	manager, err = helper.QuickSellPlayers(manager, ids, structs.LedgerEntry{
+		Amount: value,
+		Source: constant.LEDGERQUICKSELL,
+		Reason: strings.Join(names, ", "),
+		Actor:  caller.ID.Hex(),
+	})
+	if err == mongo.ErrNoDocuments {
+		helper.ReturnError(w, http.StatusConflict, fmt.Errorf("players changed while quick selling"), constant.QUICKSELLERROR)
+		return
+	}
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(request.QuickSellResponse{
+		Point:     manager.Points,
+		QuickSold: value,
+	})
+}
+func getQuickSell(w http.ResponseWriter, r *http.Request) {
+	quickSell, err := helper.GetQuickSell()
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.QUICKSELLERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(quickSell)
+}
+func updateQuickSell(w http.ResponseWriter, r *http.Request) {
+	var quickSell structs.QuickSell
+	err := json.NewDecoder(r.Body).Decode(&quickSell)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	err = helper.ValidateQuickSell(quickSell)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.QUICKSELLERROR)
+		return
+	}
+
+	quickSell, err = helper.SaveQuickSell(quickSell)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(quickSell)
+}
+func getPacks(w http.ResponseWriter, r *http.Request) {
+	caller, _ := helper.Caller(r)
+	all := r.URL.Query().Get("all") == "true" && helper.HasRole(caller, constant.ROLEADMIN)
+
+	packs, err := helper.GetPacks(!all)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.PACKERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(packs)
+}
+func savePack(w http.ResponseWriter, r *http.Request) {
+	var pack structs.PackDefinition
+	err := json.NewDecoder(r.Body).Decode(&pack)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DECODEERROR)
+		return
+	}
+
+	key, found := mux.Vars(r)["key"]
+	if found {
+		existing, err := helper.GetPack(key, 0)
+		if err != nil {
+			helper.ReturnError(w, http.StatusNotFound, err, constant.PACKERROR)
+			return
+		}
+		pack.ID = existing.ID
+		pack.Key = key
+	} else {
+		_, err = helper.GetPack(pack.Key, 0)
+		if err == nil {
+			helper.ReturnError(w, http.StatusConflict, fmt.Errorf("pack %s already exists", pack.Key), constant.PACKERROR)
+			return
+		}
+		pack.ID = primitive.NilObjectID
+	}
+
+	err = helper.ValidatePack(pack)
+	if err != nil {
+		helper.ReturnError(w, http.StatusBadRequest, err, constant.PACKERROR)
+		return
+	}
+
+	pack, err = helper.SavePack(pack)
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(pack)
+}
+func deletePack(w http.ResponseWriter, r *http.Request) {
+	deleted, err := helper.DeletePack(mux.Vars(r)["key"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.UPDATEERROR)
+		return
+	}
+	if deleted == 0 {
+		helper.ReturnError(w, http.StatusNotFound, fmt.Errorf("pack %s not found", mux.Vars(r)["key"]), constant.PACKERROR)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// rng-start
+func getSeed(w http.ResponseWriter, r *http.Request) {
+	seed, err := helper.CurrentSeed()
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DRAWERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(helper.PublishSeed(seed))
+}
+func rotateSeed(w http.ResponseWriter, r *http.Request) {
+	revealed, next, err := helper.RotateSeed()
+	if err != nil {
+		helper.ReturnError(w, http.StatusInternalServerError, err, constant.DRAWERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(request.RotateSeedResponse{
+		Revealed: revealed,
+		Next:     helper.PublishSeed(next),
+	})
+}
+func verifyDraw(w http.ResponseWriter, r *http.Request) {
+	draw, seed, verified, err := helper.VerifyDraw(mux.Vars(r)["id"])
+	if err != nil {
+		helper.ReturnError(w, http.StatusNotFound, err, constant.DRAWERROR)
+		return
+	}
+
+	json.NewEncoder(w).Encode(request.VerifyResponse{
+		Draw:     draw,
+		Seed:     helper.PublishSeed(seed),
+		Revealed: seed.RevealedAt != nil,
+		Verified: verified,
+	})
+}
+
+// rng-end
 // migration-start
 func hideByLeague() {
 	defer helper.TimeTrack(time.Now(), "migration")
@@ -535,6 +2494,12 @@ func hideByLeague() {
 	for i := 0; i < len(players); i++ {
 		fmt.Println(<-channel)
 	}
+
+	// pack indexes were built from the players as they were
+	err = helper.ClearPoolIndexes()
+	if err != nil {
+		fmt.Println("pool index err", err)
+	}
 }
 func showByTeam() {
 	defer helper.TimeTrack(time.Now(), "migration")
@@ -563,6 +2528,106 @@ func showByTeam() {
 	for i := 0; i < len(players); i++ {
 		fmt.Println(<-channel)
 	}
+
+	// pack indexes were built from the players as they were
+	err = helper.ClearPoolIndexes()
+	if err != nil {
+		fmt.Println("pool index err", err)
+	}
+}
+
+func grantAdmin(userID string) {
+	manager, err := helper.GetManager(userID)
+	if err != nil {
+		fmt.Println("manager err", err)
+		return
+	}
+
+	_, err = helper.SetManagerRole(manager.ID, constant.ROLEADMIN)
+	if err != nil {
+		fmt.Println("role err", err)
+		return
+	}
+	fmt.Println(manager.Name, "is an admin now")
+}
+func assignDefaultLeague(name string) {
+	defer helper.TimeTrack(time.Now(), "migration")
+
+	league, moved, err := helper.AssignDefaultLeague(name)
+	if err != nil {
+		fmt.Println("league err", err)
+		return
+	}
+	fmt.Println(moved, "managers moved to", league.Name, "invite code", league.InviteCode)
+}
+func deriveRarities() {
+	defer helper.TimeTrack(time.Now(), "migration")
+
+	derived, err := helper.DeriveRarities()
+	if err != nil {
+		fmt.Println("rarity err", err)
+		return
+	}
+	fmt.Println(derived, "players got a rarity")
+
+	// pack indexes were built from the players as they were
+	err = helper.ClearPoolIndexes()
+	if err != nil {
+		fmt.Println("pool index err", err)
+	}
+}
+func migrateResults() {
+	defer helper.TimeTrack(time.Now(), "migration")
+
+	moved, err := helper.MigrateEmbeddedResults()
+	if err != nil {
+		fmt.Println("migration err", err)
+		return
+	}
+	fmt.Println(moved, "embedded results moved to the results collection")
+}
+func checkLedger(fix bool) {
+	defer helper.TimeTrack(time.Now(), "ledger check")
+
+	issues, err := helper.CheckLedger(fix)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if err != nil {
+		fmt.Println("ledger err", err)
+		return
+	}
+	fmt.Println(len(issues), "issues found, fixed:", fix)
+}
+func repairResults(fix bool) {
+	defer helper.TimeTrack(time.Now(), "repair")
+
+	issues, err := helper.RepairResults(fix)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if err != nil {
+		fmt.Println("repair err", err)
+		return
+	}
+	fmt.Println(len(issues), "issues found, fixed:", fix)
+}
+func benchDraws(key string, rounds int) {
+	pack, err := helper.GetPack(key, 0)
+	if err != nil {
+		fmt.Println("pack err", err)
+		return
+	}
+
+	benchmarks, err := helper.BenchmarkDraws(pack, rounds)
+	for _, benchmark := range benchmarks {
+		fmt.Printf("%-6s %d packs in %s, %s per pack, %d documents read, %d redis commands in %d round trips\n",
+			benchmark.Name, benchmark.Rounds, benchmark.Elapsed, benchmark.Elapsed/time.Duration(benchmark.Rounds),
+			benchmark.Documents, benchmark.RedisCommands, benchmark.RedisRoundTrips)
+	}
+	if err != nil {
+		fmt.Println("benchmark err", err)
+	}
 }
 
 // migration-end
@@ -570,6 +2635,45 @@ func main() {
 	var port string
 	var err error
 
+	repair := flag.Bool("repair", false, "repair half written results and exit")
+	ledger := flag.Bool("check-ledger", false, "replay the point ledger, append opening and correcting entries where it drifted and exit")
+	dryRun := flag.Bool("dry-run", false, "only report what -repair or -check-ledger would fix")
+	admin := flag.String("admin", "", "give the manager with this Firebase user id the admin role and exit")
+	defaultLeague := flag.String("default-league", "", "move managers and seasons without a league into a new league with this name and exit")
+	migrate := flag.Bool("migrate-results", false, "move results embedded in managers and seasons to the results collection and exit")
+	rarities := flag.Bool("derive-rarities", false, "store the rarity of players without one and exit")
+	bench := flag.String("bench-draws", "", "time opening the pack with this key with every draw strategy and exit")
+	rounds := flag.Int("rounds", 100, "packs opened per strategy by -bench-draws")
+	flag.Parse()
+	if *bench != "" && *rounds > 0 {
+		benchDraws(*bench, *rounds)
+		return
+	}
+	if *admin != "" {
+		grantAdmin(*admin)
+		return
+	}
+	if *defaultLeague != "" {
+		assignDefaultLeague(*defaultLeague)
+		return
+	}
+	if *migrate {
+		migrateResults()
+		return
+	}
+	if *rarities {
+		deriveRarities()
+		return
+	}
+	if *repair {
+		repairResults(!*dryRun)
+		return
+	}
+	if *ledger {
+		checkLedger(!*dryRun)
+		return
+	}
+
 	port, err = helper.GetEnv("PORT")
 	if err != nil {
 		fmt.Println("Server will use default port")
@@ -578,20 +2682,41 @@ func main() {
 
 	router := mux.NewRouter().StrictSlash(true)
 	router.Use(commonMiddleware)
+	router.Use(authMiddleware)
+	router.Use(policyMiddleware)
 	header := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
-	methods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "OPTIONS"})
+	methods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "HEAD", "OPTIONS"})
 	origins := handlers.AllowedOrigins([]string{"*"})
 
 	router.HandleFunc("/player", home)
 	router.HandleFunc("/player/search", searchPlayer).Methods("POST", "OPTIONS")
 	router.HandleFunc("/player/random", randomPlayer).Methods("POST", "OPTIONS")
 
+	//draw session endpoints
+	router.HandleFunc("/draw", createDrawSession).Methods("POST", "OPTIONS")
+	router.HandleFunc("/draw/{id}", getDrawSession).Methods("GET", "OPTIONS")
+	router.HandleFunc("/draw/{id}/pick", drawFromSession).Methods("POST", "OPTIONS")
+	router.HandleFunc("/draw/{id}/reset", resetDrawSession).Methods("POST", "OPTIONS")
+
 	//manager endpoints
 	router.HandleFunc("/manager", getManagers).Methods("GET", "OPTIONS")
 	router.HandleFunc("/manager", createManager).Methods("POST", "OPTIONS")
 	router.HandleFunc("/manager/{id}", getManager).Methods("GET", "OPTIONS")
+	router.HandleFunc("/manager/{id}/results", getManagerResults).Methods("GET", "OPTIONS")
+	router.HandleFunc("/manager/{id}/ledger", getLedger).Methods("GET", "OPTIONS")
+	router.HandleFunc("/manager/{id}/packs", getPackOpenings).Methods("GET", "OPTIONS")
 	router.HandleFunc("/manager/player", managePlayers).Methods("POST", "OPTIONS")
 	router.HandleFunc("/manager/point", managePoints).Methods("POST", "OPTIONS")
+	router.HandleFunc("/manager/{id}/role", changeRole).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/manager/{id}/quicksell", quickSellPlayers).Methods("POST", "OPTIONS")
+
+	//league endpoints
+	router.HandleFunc("/league", getLeague).Methods("GET", "OPTIONS")
+	router.HandleFunc("/league", createLeague).Methods("POST", "OPTIONS")
+	router.HandleFunc("/league/join", joinLeague).Methods("POST", "OPTIONS")
+	router.HandleFunc("/league/leave", leaveLeague).Methods("POST", "OPTIONS")
+	router.HandleFunc("/league/invite", rotateInviteCode).Methods("POST", "OPTIONS")
+	router.HandleFunc("/league/packs", getLeaguePackStats).Methods("GET", "OPTIONS")
 
 	//redis endpoints
 	router.HandleFunc("/cleanRedis", cleanRedis).Methods("GET")
@@ -600,13 +2725,65 @@ func main() {
 	router.HandleFunc("/season", getSeasons).Methods("GET", "OPTIONS")
 	router.HandleFunc("/season", createSeason).Methods("POST", "OPTIONS")
 	router.HandleFunc("/season", changeStatus).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/season/{id}/join", joinSeason).Methods("POST", "OPTIONS")
+	router.HandleFunc("/season/{id}/leave", leaveSeason).Methods("POST", "OPTIONS")
+	router.HandleFunc("/season/{id}/rules", updateRules).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/season/{id}/fixture", getFixtures).Methods("GET", "OPTIONS")
+	router.HandleFunc("/season/{id}/fixture", createFixtures).Methods("POST", "OPTIONS")
+	router.HandleFunc("/season/{id}/bracket", getBracket).Methods("GET", "OPTIONS")
+	router.HandleFunc("/season/{id}/bracket", createBracket).Methods("POST", "OPTIONS")
+	router.HandleFunc("/season/{id}/group", createGroups).Methods("POST", "OPTIONS")
+	router.HandleFunc("/season/{id}/clubs", assignClubs).Methods("POST", "OPTIONS")
+	router.HandleFunc("/season/{id}/draft", createDraft).Methods("POST", "OPTIONS")
 	router.HandleFunc("/statistics", getStanding).Methods("POST", "OPTIONS")
 
+	//draft endpoints
+	router.HandleFunc("/draft/{id}", getDraft).Methods("GET", "OPTIONS")
+	router.HandleFunc("/draft/{id}/pick", draftPlayer).Methods("POST", "OPTIONS")
+
+	//market endpoints
+	router.HandleFunc("/market", getListings).Methods("GET", "OPTIONS")
+	router.HandleFunc("/market", createListing).Methods("POST", "OPTIONS")
+	router.HandleFunc("/market/{id}", getListing).Methods("GET", "OPTIONS")
+	router.HandleFunc("/market/{id}", cancelListing).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/market/{id}/bid", placeBid).Methods("POST", "OPTIONS")
+	router.HandleFunc("/market/{id}/buy", buyNow).Methods("POST", "OPTIONS")
+
+	//trade endpoints
+	router.HandleFunc("/trade", getTrades).Methods("GET", "OPTIONS")
+	router.HandleFunc("/trade", createTrade).Methods("POST", "OPTIONS")
+	router.HandleFunc("/trade/{id}", getTrade).Methods("GET", "OPTIONS")
+	router.HandleFunc("/trade/{id}/accept", acceptTrade).Methods("POST", "OPTIONS")
+	router.HandleFunc("/trade/{id}/reject", rejectTrade).Methods("POST", "OPTIONS")
+	router.HandleFunc("/trade/{id}/counter", counterTrade).Methods("POST", "OPTIONS")
+	router.HandleFunc("/trade/{id}/cancel", cancelTrade).Methods("POST", "OPTIONS")
+
 	//result endpoint
 	router.HandleFunc("/result", resultLogic).Methods("POST", "OPTIONS")
+	router.HandleFunc("/result/{id}", updateResult).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/result/{id}", deleteResult).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/result/{id}/audit", getResultAudits).Methods("GET", "OPTIONS")
 
 	//pack endpoint
 	router.HandleFunc("/pack", packOpener).Methods("POST", "OPTIONS")
+	router.HandleFunc("/packs", getPacks).Methods("GET", "OPTIONS")
+	router.HandleFunc("/packs", savePack).Methods("POST", "OPTIONS")
+	router.HandleFunc("/packs/{key}", savePack).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/packs/{key}", deletePack).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/quicksell", getQuickSell).Methods("GET", "OPTIONS")
+	router.HandleFunc("/quicksell", updateQuickSell).Methods("PUT", "OPTIONS")
+
+	//rng endpoints
+	router.HandleFunc("/rng/seed", getSeed).Methods("GET", "OPTIONS")
+	router.HandleFunc("/rng/seed", rotateSeed).Methods("POST", "OPTIONS")
+	router.HandleFunc("/rng/verify/{id}", verifyDraw).Methods("GET", "OPTIONS")
+
+	err = helper.PrepareCollections()
+	if err != nil {
+		log.Println("collections will be prepared on first use:", err)
+	}
+	go helper.RunDraftClock(constant.DRAFTCLOCKSECONDS * time.Second)
+	go helper.RunMarket(constant.MARKETCLOCKSECONDS * time.Second)
 
 	log.Fatal(http.ListenAndServe(":"+port, handlers.CORS(header, methods, origins)(router)))
 }
@@ -617,3 +2794,84 @@ func commonMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// publicRoutes don't need a token, tokenOnlyRoutes need a valid token but
+// the caller doesn't have to be a manager yet, e.g. while signing up.
+var publicRoutes = map[string]bool{
+	"/player":          true,
+	"/player/search":   true,
+	"/player/random":   true,
+	"/rng/seed":        true,
+	"/rng/verify/{id}": true,
+	"/draw":            true,
+	"/draw/{id}":       true,
+	"/draw/{id}/pick":  true,
+	"/draw/{id}/reset": true,
+}
+var tokenOnlyRoutes = map[string]bool{
+	"POST /manager":     true,
+	"GET /manager/{id}": true,
+}
+
+// routeRoles limits routes to managers holding one of the roles, ownership
+// is checked by the handlers themselves.
+var routeRoles = map[string][]string{
+	"GET /cleanRedis":        {constant.ROLEADMIN},
+	"POST /manager/point":    {constant.ROLEADMIN},
+	"PUT /manager/{id}/role": {constant.ROLEADMIN},
+	"POST /season":           {constant.ROLEADMIN, constant.ROLEOWNER},
+	"PUT /season":            {constant.ROLEADMIN, constant.ROLEOWNER},
+	"POST /packs":            {constant.ROLEADMIN},
+	"PUT /packs/{key}":       {constant.ROLEADMIN},
+	"DELETE /packs/{key}":    {constant.ROLEADMIN},
+	"PUT /quicksell":         {constant.ROLEADMIN},
+	"POST /rng/seed":         {constant.ROLEADMIN},
+}
+
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		template, _ := mux.CurrentRoute(r).GetPathTemplate()
+		if r.Method == http.MethodOptions || publicRoutes[template] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		idToken, err := helper.BearerToken(r)
+		if err != nil {
+			helper.ReturnError(w, http.StatusUnauthorized, err, constant.AUTHERROR)
+			return
+		}
+		token, err := helper.VerifyIDToken(idToken)
+		if err != nil {
+			helper.ReturnError(w, http.StatusUnauthorized, err, constant.AUTHERROR)
+			return
+		}
+		ctx := helper.WithToken(r.Context(), token)
+
+		caller, err := helper.GetManager(token.Subject)
+		if err == nil {
+			ctx = helper.WithCaller(ctx, caller)
+		} else if !tokenOnlyRoutes[r.Method+" "+template] {
+			helper.ReturnError(w, http.StatusUnauthorized, fmt.Errorf("no manager found for the signed in user"), constant.AUTHERROR)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+func policyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		template, _ := mux.CurrentRoute(r).GetPathTemplate()
+		roles, found := routeRoles[r.Method+" "+template]
+		if found {
+			caller, _ := helper.Caller(r)
+			err := helper.AuthorizeRoles(caller, roles...)
+			if err != nil {
+				helper.ReturnForbidden(w, err)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
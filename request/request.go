@@ -2,14 +2,20 @@ package request
 
 import (
 	"manager-sensin/structs"
+	"time"
 )
 
 type ResultRequest struct {
-	Season  string          `json:"season,omitempty"`
-	Home    string          `json:"home,omitempty"`
-	Away    string          `json:"away,omitempty"`
-	Score   []int           `json:"score,omitempty"`
-	Scorers []ScorerRequest `json:"scorer,omitempty"`
+	Season    string          `json:"season,omitempty"`
+	Home      string          `json:"home,omitempty"`
+	Away      string          `json:"away,omitempty"`
+	Score     []int           `json:"score,omitempty"`
+	Fixture   string          `json:"fixture,omitempty"`
+	ExtraTime bool            `json:"extraTime,omitempty"`
+	Penalties []int           `json:"penalties,omitempty"`
+	Yellow    []int           `json:"yellowCards,omitempty"`
+	Red       []int           `json:"redCards,omitempty"`
+	Scorers   []ScorerRequest `json:"scorer,omitempty"`
 }
 type ScorerRequest struct {
 	Player  string `json:"player,omitempty"`
@@ -20,18 +26,55 @@ type StatisticRequest struct {
 	Season string `json:"season,omitempty"`
 }
 type StatisticResponse struct {
-	Standing []structs.Standing `json:"standing"`
-	Stats    []structs.Stats    `json:"stats"`
+	Standing []structs.Standing      `json:"standing"`
+	Groups   []structs.GroupStanding `json:"groups,omitempty"`
+	Stats    []structs.Stats         `json:"stats"`
 }
 type SeasonRequest struct {
 	ID       string `json:"id,omitempty"`
 	IsActive bool   `json:"isActive,omitempty"`
+	Status   string `json:"status,omitempty"`
 }
 type SeasonResponse struct {
 	ID       string `json:"id,omitempty" bson:"_id,omitempty"`
 	Title    string `json:"title,omitempty"`
 	Type     string `json:"type,omitempty"`
-	IsActive bool   `json:"isActive"`
+	IsActive bool   `json:"isActive" bson:"isActive"`
+	Status   string `json:"status" bson:"status"`
+}
+type ParticipantRequest struct {
+	Manager string `json:"manager,omitempty"`
+}
+type FixtureRequest struct {
+	Managers []string `json:"managers,omitempty"`
+	Double   bool     `json:"double,omitempty"`
+	Shuffle  bool     `json:"shuffle,omitempty"`
+}
+type BracketRequest struct {
+	Managers  []string `json:"managers,omitempty"`
+	Seeded    bool     `json:"seeded,omitempty"`
+	TwoLegged bool     `json:"twoLegged,omitempty"`
+}
+type BracketResponse struct {
+	Season    string         `json:"season"`
+	TwoLegged bool           `json:"twoLegged"`
+	Rounds    []BracketRound `json:"rounds"`
+}
+type BracketRound struct {
+	Name string       `json:"name"`
+	Ties []BracketTie `json:"ties"`
+}
+type BracketTie struct {
+	structs.Tie
+	Legs []structs.Fixture `json:"legs"`
+}
+type GroupRequest struct {
+	Managers   []string `json:"managers,omitempty"`
+	Groups     int      `json:"groups,omitempty"`
+	Qualifiers int      `json:"qualifiers,omitempty"`
+	Seeded     bool     `json:"seeded,omitempty"`
+	Double     bool     `json:"double,omitempty"`
+	TwoLegged  bool     `json:"twoLegged,omitempty"`
 }
 type ManagePlayer struct {
 	Manager string `json:"manager,omitempty"`
@@ -42,6 +85,35 @@ type ManagePoint struct {
 	Manager string `json:"manager,omitempty"`
 	Point   int    `json:"point,omitempty"`
 	Type    int    `json:"type,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+type LeagueRequest struct {
+	Name string `json:"name,omitempty"`
+	Code string `json:"code,omitempty"`
+}
+type LeagueResponse struct {
+	structs.League
+	Managers []structs.Manager `json:"managers"`
+}
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+type LedgerResponse struct {
+	Entries []structs.LedgerEntry `json:"entries"`
+	Page    int                   `json:"page"`
+	Size    int                   `json:"size"`
+	Total   int64                 `json:"total"`
+}
+type PackOpeningResponse struct {
+	Openings []structs.PackOpening `json:"openings"`
+	Page     int                   `json:"page"`
+	Size     int                   `json:"size"`
+	Total    int64                 `json:"total"`
+}
+type LeaguePackStatsResponse struct {
+	Since    time.Time                  `json:"since"`
+	Pulls    []structs.PackPull         `json:"pulls"`
+	Managers []structs.ManagerPackStats `json:"managers"`
 }
 type Filter struct {
 	Name        string `json:"name,omitempty"`
@@ -52,19 +124,102 @@ type Filter struct {
 	Overall     []int  `json:"overall,omitempty"`
 	Potential   []int  `json:"potential,omitempty"`
 	Position    string `json:"position,omitempty"`
+	ClientSeed  string `json:"clientSeed,omitempty"`
+}
+type ClubRequest struct {
+	Managers   []string `json:"managers,omitempty"`
+	League     string   `json:"league,omitempty"`
+	MinOverall float64  `json:"minOverall,omitempty"`
+	MaxOverall float64  `json:"maxOverall,omitempty"`
+	Spread     float64  `json:"spread,omitempty"`
+	Matchday   int      `json:"matchday,omitempty"`
+}
+type DraftRequest struct {
+	Managers        []string `json:"managers,omitempty"`
+	Order           string   `json:"order,omitempty"`
+	Mode            string   `json:"mode,omitempty"`
+	Positions       []string `json:"positions,omitempty"`
+	PickSeconds     int      `json:"pickSeconds,omitempty"`
+	StandingsSeason string   `json:"standingsSeason,omitempty"`
+}
+type DraftPickRequest struct {
+	Player string `json:"player"`
+}
+type DraftBoard struct {
+	structs.Draft
+	OnTheClock string   `json:"onTheClock,omitempty"`
+	Round      int      `json:"round,omitempty"`
+	OpenSlots  []string `json:"openSlots,omitempty"`
+}
+type ListingRequest struct {
+	Manager    string `json:"manager"`
+	Player     string `json:"player"`
+	StartPrice int    `json:"startPrice"`
+	BuyNow     int    `json:"buyNow,omitempty"`
+	Hours      int    `json:"hours,omitempty"`
+}
+type BidRequest struct {
+	Amount int `json:"amount"`
+}
+
+// TradeRequest proposes a trade from Manager to To. Counters only use the
+// offered and requested sides, the managers swap.
+type TradeRequest struct {
+	Manager   string     `json:"manager"`
+	To        string     `json:"to"`
+	Offered   TradeOffer `json:"offered"`
+	Requested TradeOffer `json:"requested"`
+}
+type TradeOffer struct {
+	Players []string `json:"players"`
+	Points  int      `json:"points,omitempty"`
+}
+type DrawSessionRequest struct {
+	Filter
+	Minutes int `json:"minutes,omitempty"`
+}
+type DrawSessionResponse struct {
+	ID        string           `json:"id"`
+	Remaining int              `json:"remaining"`
+	Drawn     []structs.Player `json:"drawn"`
+	ExpiresAt time.Time        `json:"expiresAt,omitempty"`
 }
 type Pack struct {
-	Type    int    `json:"type,omitempty"`
-	Manager string `json:"manager,omitempty"`
+	Type       int    `json:"type,omitempty"`
+	Pack       string `json:"pack,omitempty"`
+	Manager    string `json:"manager,omitempty"`
+	ClientSeed string `json:"clientSeed,omitempty"`
 }
 type PackResponse struct {
-	Point   int            `json:"point,omitempty"`
-	Player  structs.Player `json:"player,omitempty"`
-	Message string         `json:"message,omitempty"`
+	Point      int              `json:"point,omitempty"`
+	Player     structs.Player   `json:"player,omitempty"`
+	Players    []structs.Player `json:"players,omitempty"`
+	Duplicates []structs.Player `json:"duplicates,omitempty"`
+	QuickSold  int              `json:"quickSold,omitempty"`
+	Draw       string           `json:"draw,omitempty"`
+	Message    string           `json:"message,omitempty"`
+}
+type QuickSellRequest struct {
+	Players []string `json:"players"`
+}
+type QuickSellResponse struct {
+	Point     int `json:"point"`
+	QuickSold int `json:"quickSold"`
 }
 type Response struct {
 	Count   int              `json:"count,omitempty"`
 	Players []structs.Player `json:"players,omitempty"`
+	Draw    string           `json:"draw,omitempty"`
+}
+type VerifyResponse struct {
+	Draw     structs.Draw       `json:"draw"`
+	Seed     structs.ServerSeed `json:"seed"`
+	Revealed bool               `json:"revealed"`
+	Verified bool               `json:"verified"`
+}
+type RotateSeedResponse struct {
+	Revealed []structs.ServerSeed `json:"revealed"`
+	Next     structs.ServerSeed   `json:"next"`
 }
 type ErrorResponse struct {
 	Code    int    `json:"code,omitempty"`
@@ -1,6 +1,13 @@
 package structs
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"fmt"
+	"manager-sensin/constant"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Player struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty"`
@@ -27,6 +34,7 @@ type Player struct {
 	SM           int                `bson:"skill_moves,omitempty"`
 	WorkRate     string             `bson:"work_rate,omitempty"`
 	Foot         string             `bson:"preferred_foot,omitempty"`
+	Rarity       string             `bson:"rarity,omitempty"`
 	Hidden       bool               `bson:"hidden,omitempty"`
 }
 
@@ -35,9 +43,10 @@ type Manager struct {
 	Name    string             `json:"name,omitempty" bson:"name,omitempty"`
 	Email   string             `json:"email,omitempty" bson:"email,omitempty"`
 	UserID  string             `json:"userID,omitempty" bson:"userID,omitempty"`
+	Role    string             `json:"role,omitempty" bson:"role,omitempty"`
+	League  string             `json:"league,omitempty" bson:"league,omitempty"`
 	Points  int                `bson:"points,omitempty"`
 	Players []Player           `bson:"players,omitempty"`
-	Results []Result           `bson:"results,omitempty"`
 	Badges  []string           `bson:"badges,omitempty"`
 }
 
@@ -51,16 +60,359 @@ type Result struct {
 	HomeManager string             `json:"homeManager,omitempty" bson:"homeManager,omitempty"`
 	AwayManager string             `json:"awayManager,omitempty" bson:"awayManager,omitempty"`
 	Score       []int              `json:"score,omitempty" bson:"score,omitempty"`
+	Fixture     string             `json:"fixture,omitempty" bson:"fixture,omitempty"`
+	Matchday    int                `json:"matchday,omitempty" bson:"matchday,omitempty"`
+	ExtraTime   bool               `json:"extraTime,omitempty" bson:"extraTime,omitempty"`
+	Penalties   []int              `json:"penalties,omitempty" bson:"penalties,omitempty"`
+	Group       string             `json:"group,omitempty" bson:"group,omitempty"`
+	YellowCards []int              `json:"yellowCards,omitempty" bson:"yellowCards,omitempty"`
+	RedCards    []int              `json:"redCards,omitempty" bson:"redCards,omitempty"`
 	HomeScorers []Scorer           `json:"homescorers,omitempty" bson:"homescorers,omitempty"`
 	AwayScorers []Scorer           `json:"awayscorers,omitempty" bson:"awayscorers,omitempty"`
+	HomeClub    string             `json:"homeClub,omitempty" bson:"homeClub,omitempty"`
+	AwayClub    string             `json:"awayClub,omitempty" bson:"awayClub,omitempty"`
+}
+
+type ResultAudit struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Result string             `json:"result" bson:"result"`
+	Season string             `json:"season" bson:"season"`
+	Action string             `json:"action" bson:"action"`
+	Actor  string             `json:"actor,omitempty" bson:"actor,omitempty"`
+	Before *Result            `json:"before,omitempty" bson:"before,omitempty"`
+	After  *Result            `json:"after,omitempty" bson:"after,omitempty"`
+	At     time.Time          `json:"at" bson:"at"`
+}
+
+type League struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name       string             `json:"name" bson:"name"`
+	Owner      string             `json:"owner" bson:"owner"`
+	InviteCode string             `json:"inviteCode,omitempty" bson:"inviteCode"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
 }
 
 type Season struct {
-	ID       primitive.ObjectID `bson:"_id,omitempty"`
-	Type     string             `json:"type,omitempty" bson:"type,omitempty"`
-	Title    string             `json:"title,omitempty" bson:"title,omitempty"`
-	IsActive bool               `json:"isActive" bson:"isActive"`
-	Results  []Result           `bson:"results,omitempty"`
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	Type          string             `json:"type,omitempty" bson:"type,omitempty"`
+	Title         string             `json:"title,omitempty" bson:"title,omitempty"`
+	Owner         string             `json:"owner,omitempty" bson:"owner,omitempty"`
+	League        string             `json:"league,omitempty" bson:"league,omitempty"`
+	IsActive      bool               `json:"isActive" bson:"isActive"`
+	Status        string             `json:"status" bson:"status"`
+	Participants  []string           `json:"participants,omitempty" bson:"participants,omitempty"`
+	Meetings      int                `json:"meetings,omitempty" bson:"meetings,omitempty"`
+	Fixtures      []Fixture          `json:"fixtures,omitempty" bson:"fixtures,omitempty"`
+	Bracket       *Bracket           `json:"bracket,omitempty" bson:"bracket,omitempty"`
+	Groups        []Group            `json:"groups,omitempty" bson:"groups,omitempty"`
+	Qualifiers    int                `json:"qualifiers,omitempty" bson:"qualifiers,omitempty"`
+	TwoLegged     bool               `json:"twoLegged,omitempty" bson:"twoLegged,omitempty"`
+	Rules         *Rules             `json:"rules,omitempty" bson:"rules,omitempty"`
+	FinalStanding []Standing         `json:"finalStanding,omitempty" bson:"finalStanding,omitempty"`
+	FinalGroups   []GroupStanding    `json:"finalGroups,omitempty" bson:"finalGroups,omitempty"`
+	Champion      string             `json:"champion,omitempty" bson:"champion,omitempty"`
+	ClubRounds    []ClubRound        `json:"clubRounds,omitempty" bson:"clubRounds,omitempty"`
+	Draft         string             `json:"draft,omitempty" bson:"draft,omitempty"`
+}
+
+// ClubRound is one random assignment of clubs to managers. A round with a
+// matchday only applies to that matchday's results.
+type ClubRound struct {
+	Round       int              `json:"round" bson:"round"`
+	Matchday    int              `json:"matchday,omitempty" bson:"matchday,omitempty"`
+	Assignments []ClubAssignment `json:"assignments" bson:"assignments"`
+	At          time.Time        `json:"at" bson:"at"`
+}
+type ClubAssignment struct {
+	Manager     string  `json:"manager" bson:"manager"`
+	ManagerName string  `json:"managerName" bson:"managerName"`
+	Club        string  `json:"club" bson:"club"`
+	League      string  `json:"league" bson:"league"`
+	Overall     float64 `json:"overall" bson:"overall"`
+}
+
+// Draft hands out players to the managers of a season in turns. Order is the
+// first round's order, snake drafts reverse it every other round. Every
+// manager drafts one player for each of the Positions.
+type Draft struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Season      string             `json:"season" bson:"season"`
+	League      string             `json:"league,omitempty" bson:"league,omitempty"`
+	Status      string             `json:"status" bson:"status"`
+	Snake       bool               `json:"snake" bson:"snake"`
+	Order       []string           `json:"order" bson:"order"`
+	Positions   []string           `json:"positions" bson:"positions"`
+	PickSeconds int                `json:"pickSeconds,omitempty" bson:"pickSeconds,omitempty"`
+	Picks       []DraftPick        `json:"picks" bson:"picks"`
+	Deadline    *time.Time         `json:"deadline,omitempty" bson:"deadline,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+}
+type DraftPick struct {
+	Number   int                `json:"number" bson:"number"`
+	Round    int                `json:"round" bson:"round"`
+	Manager  string             `json:"manager" bson:"manager"`
+	Player   primitive.ObjectID `json:"player" bson:"player"`
+	Name     string             `json:"name" bson:"name"`
+	Overall  int                `json:"overall" bson:"overall"`
+	Position string             `json:"position" bson:"position"`
+	Auto     bool               `json:"auto,omitempty" bson:"auto,omitempty"`
+	At       time.Time          `json:"at" bson:"at"`
+}
+
+type Rules struct {
+	Win         int      `json:"win" bson:"win"`
+	Draw        int      `json:"draw" bson:"draw"`
+	Loss        int      `json:"loss" bson:"loss"`
+	Tiebreakers []string `json:"tiebreakers" bson:"tiebreakers"`
+}
+
+type Group struct {
+	Name     string   `json:"name" bson:"name"`
+	Managers []string `json:"managers" bson:"managers"`
+}
+
+type GroupStanding struct {
+	Group    string     `json:"group" bson:"group"`
+	Standing []Standing `json:"standing" bson:"standing"`
+}
+
+type Fixture struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Matchday    int                `json:"matchday" bson:"matchday"`
+	Home        string             `json:"home" bson:"home"`
+	Away        string             `json:"away,omitempty" bson:"away,omitempty"`
+	HomeManager string             `json:"homeManager" bson:"homeManager"`
+	AwayManager string             `json:"awayManager,omitempty" bson:"awayManager,omitempty"`
+	Bye         bool               `json:"bye,omitempty" bson:"bye,omitempty"`
+	Tie         string             `json:"tie,omitempty" bson:"tie,omitempty"`
+	Group       string             `json:"group,omitempty" bson:"group,omitempty"`
+	Result      string             `json:"result,omitempty" bson:"result,omitempty"`
+	Score       []int              `json:"score,omitempty" bson:"score,omitempty"`
+	ExtraTime   bool               `json:"extraTime,omitempty" bson:"extraTime,omitempty"`
+	Penalties   []int              `json:"penalties,omitempty" bson:"penalties,omitempty"`
+}
+
+type Bracket struct {
+	TwoLegged bool    `json:"twoLegged" bson:"twoLegged"`
+	Rounds    []Round `json:"rounds" bson:"rounds"`
+}
+
+type Round struct {
+	Name     string `json:"name" bson:"name"`
+	Matchday int    `json:"matchday" bson:"matchday"`
+	Ties     []Tie  `json:"ties" bson:"ties"`
+}
+
+type Tie struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Home        string             `json:"home,omitempty" bson:"home,omitempty"`
+	Away        string             `json:"away,omitempty" bson:"away,omitempty"`
+	HomeManager string             `json:"homeManager,omitempty" bson:"homeManager,omitempty"`
+	AwayManager string             `json:"awayManager,omitempty" bson:"awayManager,omitempty"`
+	Legs        []string           `json:"legs,omitempty" bson:"legs,omitempty"`
+	Aggregate   []int              `json:"aggregate,omitempty" bson:"aggregate,omitempty"`
+	Penalties   []int              `json:"penalties,omitempty" bson:"penalties,omitempty"`
+	Winner      string             `json:"winner,omitempty" bson:"winner,omitempty"`
+}
+
+type LedgerEntry struct {
+	ID      primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Manager string             `json:"manager" bson:"manager"`
+	Amount  int                `json:"amount" bson:"amount"`
+	Balance int                `json:"balance" bson:"balance"`
+	Source  string             `json:"source" bson:"source"`
+	Reason  string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	Actor   string             `json:"actor,omitempty" bson:"actor,omitempty"`
+	At      time.Time          `json:"at" bson:"at"`
+}
+
+type PackDefinition struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Key        string             `json:"key" bson:"key"`
+	Type       *int               `json:"type,omitempty" bson:"type,omitempty"`
+	Name       string             `json:"name" bson:"name"`
+	Price      int                `json:"price" bson:"price"`
+	Players    int                `json:"players" bson:"players"`
+	OnSale     bool               `json:"onSale" bson:"onSale"`
+	Odds       []PackOdds         `json:"odds" bson:"odds"`
+	Guarantees []PackSlot         `json:"guarantees,omitempty" bson:"guarantees,omitempty"`
+	Filter     *PackFilter        `json:"filter,omitempty" bson:"filter,omitempty"`
+	UpdatedAt  time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// PackOdds is one row of a pack's odds table, a slot picks a row by weight
+// and then a random player matching it. Rows without a rarity match players
+// of any rarity.
+type PackOdds struct {
+	Label      string   `json:"label,omitempty" bson:"label,omitempty"`
+	Weight     int      `json:"weight" bson:"weight"`
+	MinOverall int      `json:"minOverall" bson:"minOverall"`
+	MaxOverall int      `json:"maxOverall" bson:"maxOverall"`
+	Rarity     string   `json:"rarity,omitempty" bson:"rarity,omitempty"`
+	Leagues    []string `json:"leagues,omitempty" bson:"leagues,omitempty"`
+	Chance     float64  `json:"chance,omitempty" bson:"-"`
+}
+
+// PackSlot guarantees Count players drawn from its own odds table, e.g. one
+// player rated 80 or more. Slots not covered by a guarantee use the pack odds.
+type PackSlot struct {
+	Label string     `json:"label,omitempty" bson:"label,omitempty"`
+	Count int        `json:"count" bson:"count"`
+	Odds  []PackOdds `json:"odds" bson:"odds"`
+}
+
+// PackFilter narrows every slot of a pack, e.g. for nation themed packs.
+type PackFilter struct {
+	Nations []string `json:"nations,omitempty" bson:"nations,omitempty"`
+	Leagues []string `json:"leagues,omitempty" bson:"leagues,omitempty"`
+	Clubs   []string `json:"clubs,omitempty" bson:"clubs,omitempty"`
+}
+
+// QuickSell prices players by overall, a player is worth the value of the
+// highest band it reaches.
+type QuickSell struct {
+	ID         string          `json:"-" bson:"_id"`
+	Duplicates bool            `json:"duplicates" bson:"duplicates"`
+	Bands      []QuickSellBand `json:"bands" bson:"bands"`
+}
+
+// QuickSellBand prices players from MinOverall up, a band with a Rarity only
+// prices players of that rarity.
+type QuickSellBand struct {
+	MinOverall int    `json:"minOverall" bson:"minOverall"`
+	Rarity     string `json:"rarity,omitempty" bson:"rarity,omitempty"`
+	Value      int    `json:"value" bson:"value"`
+}
+
+// PackOpening is the history record of an opened pack.
+type PackOpening struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Manager    string             `json:"manager" bson:"manager"`
+	League     string             `json:"league,omitempty" bson:"league,omitempty"`
+	Pack       string             `json:"pack" bson:"pack"`
+	Name       string             `json:"name" bson:"name"`
+	Price      int                `json:"price" bson:"price"`
+	Players    []Player           `json:"players" bson:"players"`
+	Duplicates []Player           `json:"duplicates,omitempty" bson:"duplicates,omitempty"`
+	QuickSold  int                `json:"quickSold,omitempty" bson:"quickSold,omitempty"`
+	Draw       primitive.ObjectID `json:"draw" bson:"draw"`
+	ServerSeed primitive.ObjectID `json:"serverSeed" bson:"serverSeed"`
+	Nonce      int                `json:"nonce" bson:"nonce"`
+	At         time.Time          `json:"at" bson:"at"`
+}
+
+// PackPull is one player pulled from a pack, used to rank the best pulls.
+type PackPull struct {
+	Manager string    `json:"manager" bson:"manager"`
+	Pack    string    `json:"pack" bson:"name"`
+	Player  Player    `json:"player" bson:"players"`
+	At      time.Time `json:"at" bson:"at"`
+}
+
+// ManagerPackStats sums up a manager's pack openings.
+type ManagerPackStats struct {
+	Manager     string `json:"manager" bson:"_id"`
+	Name        string `json:"name" bson:"-"`
+	Opened      int    `json:"opened" bson:"opened"`
+	Spent       int    `json:"spent" bson:"spent"`
+	QuickSold   int    `json:"quickSold" bson:"quickSold"`
+	BestOverall int    `json:"bestOverall" bson:"bestOverall"`
+}
+
+// Listing puts a player on the transfer market. The highest bid is held in
+// escrow, taken from the bidder when bidding and refunded when outbid. Active
+// is only set while the listing is open so a player can't be listed twice.
+type Listing struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Seller     string             `json:"seller" bson:"seller"`
+	SellerName string             `json:"sellerName" bson:"sellerName"`
+	League     string             `json:"league,omitempty" bson:"league,omitempty"`
+	Player     Player             `json:"player" bson:"player"`
+	StartPrice int                `json:"startPrice" bson:"startPrice"`
+	BuyNow     int                `json:"buyNow,omitempty" bson:"buyNow,omitempty"`
+	HighBid    int                `json:"highBid,omitempty" bson:"highBid,omitempty"`
+	HighBidder string             `json:"highBidder,omitempty" bson:"highBidder,omitempty"`
+	Bids       []Bid              `json:"bids" bson:"bids"`
+	Status     string             `json:"status" bson:"status"`
+	Active     string             `json:"-" bson:"active,omitempty"`
+	EndsAt     time.Time          `json:"endsAt" bson:"endsAt"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	SettledAt  *time.Time         `json:"settledAt,omitempty" bson:"settledAt,omitempty"`
+}
+type Bid struct {
+	Manager string    `json:"manager" bson:"manager"`
+	Name    string    `json:"name" bson:"name"`
+	Amount  int       `json:"amount" bson:"amount"`
+	At      time.Time `json:"at" bson:"at"`
+}
+
+// Trade offers players and points of From for players and points of To.
+// Only To can accept, reject or counter a pending trade, only From can cancel
+// it. A counter closes the trade and opens a new one the other way round.
+type Trade struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	League      string             `json:"league,omitempty" bson:"league,omitempty"`
+	From        string             `json:"from" bson:"from"`
+	FromName    string             `json:"fromName" bson:"fromName"`
+	To          string             `json:"to" bson:"to"`
+	ToName      string             `json:"toName" bson:"toName"`
+	Offered     TradeSide          `json:"offered" bson:"offered"`
+	Requested   TradeSide          `json:"requested" bson:"requested"`
+	Status      string             `json:"status" bson:"status"`
+	CounterOf   string             `json:"counterOf,omitempty" bson:"counterOf,omitempty"`
+	CounteredBy string             `json:"counteredBy,omitempty" bson:"counteredBy,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	ClosedAt    *time.Time         `json:"closedAt,omitempty" bson:"closedAt,omitempty"`
+}
+type TradeSide struct {
+	Players []Player `json:"players" bson:"players"`
+	Points  int      `json:"points" bson:"points"`
+}
+
+func (s TradeSide) PlayerIDs() []primitive.ObjectID {
+	ids := []primitive.ObjectID{}
+	for _, player := range s.Players {
+		ids = append(ids, player.ID)
+	}
+	return ids
+}
+
+// ServerSeed is the server half of a provably fair draw. Only its hash is
+// published until the seed is revealed, Nonce counts the draws made with it.
+type ServerSeed struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Seed       string             `json:"seed,omitempty" bson:"seed"`
+	Hash       string             `json:"hash" bson:"hash"`
+	Nonce      int                `json:"nonce" bson:"nonce"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	RevealedAt *time.Time         `json:"revealedAt,omitempty" bson:"revealedAt,omitempty"`
+}
+
+// Draw records everything needed to replay a pack opening or random player.
+type Draw struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Kind       string             `json:"kind" bson:"kind"`
+	Manager    string             `json:"manager,omitempty" bson:"manager,omitempty"`
+	Pack       string             `json:"pack,omitempty" bson:"pack,omitempty"`
+	ServerSeed primitive.ObjectID `json:"serverSeed" bson:"serverSeed"`
+	ClientSeed string             `json:"clientSeed" bson:"clientSeed"`
+	Nonce      int                `json:"nonce" bson:"nonce"`
+	Picks      []DrawPick         `json:"picks" bson:"picks"`
+	At         time.Time          `json:"at" bson:"at"`
+}
+
+// DrawPick is one player of a draw. Total is the summed odds weight Roll was
+// drawn from, zero when no odds were rolled. PoolHash names the stored list
+// of candidates, Skipped the candidates left out of it, like earlier picks of
+// the draw. Index points into the Pool players remaining.
+type DrawPick struct {
+	Total    int                  `json:"total,omitempty" bson:"total,omitempty"`
+	Roll     int                  `json:"roll" bson:"roll"`
+	Pool     int                  `json:"pool" bson:"pool"`
+	PoolHash string               `json:"poolHash" bson:"poolHash"`
+	Skipped  []primitive.ObjectID `json:"skipped,omitempty" bson:"skipped,omitempty"`
+	Index    int                  `json:"index" bson:"index"`
+	Player   primitive.ObjectID   `json:"player" bson:"player"`
 }
 
 type Scorer struct {
@@ -73,22 +425,25 @@ type Insert struct {
 }
 
 type Standing struct {
-	Manager string   `json:"manager"`
-	Points  int      `json:"points"`
-	Played  int      `json:"played"`
-	Won     int      `json:"won"`
-	Draw    int      `json:"draw"`
-	Lost    int      `json:"lost"`
-	GF      int      `json:"gf"`
-	GA      int      `json:"ga"`
-	GD      int      `json:"gd"`
-	Form    []string `json:"form"`
+	ID         string   `json:"id"`
+	Manager    string   `json:"manager"`
+	Points     int      `json:"points"`
+	Played     int      `json:"played"`
+	Won        int      `json:"won"`
+	Draw       int      `json:"draw"`
+	Lost       int      `json:"lost"`
+	GF         int      `json:"gf"`
+	GA         int      `json:"ga"`
+	GD         int      `json:"gd"`
+	FairPlay   int      `json:"fairPlay"`
+	Form       []string `json:"form"`
+	Tiebreaker string   `json:"tiebreaker,omitempty"`
 }
 type Stats struct {
-	Manager string `json:"manager"`
-	Player  string `json:"player"`
-	Count   int    `json:"count"`
-	FaceUrl string `json:"faceUrl"`
+	Manager string `json:"manager" bson:"manager"`
+	Player  string `json:"player" bson:"player"`
+	Count   int    `json:"count" bson:"count"`
+	FaceUrl string `json:"faceUrl" bson:"faceUrl"`
 }
 
 //manager-logic
@@ -104,6 +459,18 @@ func (m *Manager) playerExist(playerID primitive.ObjectID) (bool, int) {
 	}
 	return found, foundIndex
 }
+func (m *Manager) Owns(playerID primitive.ObjectID) bool {
+	found, _ := m.playerExist(playerID)
+	return found
+}
+func (m *Manager) OwnedPlayer(id string) (bool, Player) {
+	for _, player := range m.Players {
+		if player.ID.Hex() == id {
+			return true, player
+		}
+	}
+	return false, Player{}
+}
 func (m *Manager) AddPlayer(p Player) {
 	found, _ := m.playerExist(p.ID)
 	if !found {
@@ -125,18 +492,228 @@ func (m *Manager) ManagePoint(point, pointType int) {
 	}
 }
 
-func (m *Manager) AddResult(r Result) {
-	m.Results = append(m.Results, r)
+//season-logic
+func (s *Season) ChangeStatus(status string) {
+	s.Status = status
+	s.IsActive = status == constant.STATUSACTIVE
+}
+func (s *Season) Locked() bool {
+	return s.Status == constant.STATUSFINISHED || s.Status == constant.STATUSARCHIVED
+}
+func (s *Season) PendingFixture(fixtureID, home, away string) (bool, int) {
+	for i, fixture := range s.Fixtures {
+		if fixture.Bye || fixture.Result != "" {
+			continue
+		}
+		if len(fixtureID) > 0 && fixture.ID.Hex() != fixtureID {
+			continue
+		}
+		if fixture.Home == home && fixture.Away == away {
+			return true, i
+		}
+	}
+	return false, 0
+}
+func (s *Season) PlayFixture(index int, r Result) {
+	s.Fixtures[index].Result = r.ID.Hex()
+	s.Fixtures[index].Score = r.Score
+	s.Fixtures[index].ExtraTime = r.ExtraTime
+	s.Fixtures[index].Penalties = r.Penalties
+}
+func (s *Season) UnplayFixture(index int) {
+	s.Fixtures[index].Result = ""
+	s.Fixtures[index].Score = nil
+	s.Fixtures[index].ExtraTime = false
+	s.Fixtures[index].Penalties = nil
+}
+func (s *Season) HasPlayedFixture() bool {
+	for _, fixture := range s.Fixtures {
+		if fixture.Result != "" {
+			return true
+		}
+	}
+	return false
+}
+func (s *Season) IsParticipant(managerID string) (bool, int) {
+	for i, participant := range s.Participants {
+		if participant == managerID {
+			return true, i
+		}
+	}
+	return false, 0
 }
 
-//season-logic
-func (s *Season) ChangeStatus(isActive bool) {
-	s.IsActive = isActive
+// Registered tells whether a manager plays in the season. Seasons scheduled
+// without registration count the managers of their fixtures as registered,
+// seasons with neither, like the ones from before registration, stay open.
+func (s *Season) Registered(managerID string) bool {
+	if len(s.Participants) > 0 {
+		found, _ := s.IsParticipant(managerID)
+		return found
+	}
+	if len(s.Fixtures) == 0 {
+		return true
+	}
+	for _, fixture := range s.Fixtures {
+		if fixture.Home == managerID || fixture.Away == managerID {
+			return true
+		}
+	}
+	return false
+}
+func (s *Season) Join(managerID string) {
+	found, _ := s.IsParticipant(managerID)
+	if !found {
+		s.Participants = append(s.Participants, managerID)
+	}
+}
+func (s *Season) Leave(managerID string) {
+	found, index := s.IsParticipant(managerID)
+	if found {
+		s.Participants = append(s.Participants[:index], s.Participants[index+1:]...)
+	}
+}
+
+// Entrants falls back to the registered participants when no managers are
+// given and rejects managers who didn't register for a season with a list.
+func (s *Season) Entrants(managerIDs []string) ([]string, error) {
+	if len(managerIDs) == 0 {
+		return s.Participants, nil
+	}
+	seen := make(map[string]bool)
+	for _, id := range managerIDs {
+		if seen[id] {
+			return managerIDs, fmt.Errorf("manager %s is listed twice", id)
+		}
+		seen[id] = true
+	}
+	if len(s.Participants) > 0 {
+		for _, id := range managerIDs {
+			found, _ := s.IsParticipant(id)
+			if !found {
+				return managerIDs, fmt.Errorf("manager %s is not registered for the season", id)
+			}
+		}
+	}
+	return managerIDs, nil
+}
+
+// PairMeetings counts the scheduled and played fixtures between two managers,
+// regardless of who played at home.
+func (s *Season) PairMeetings(a, b string) (int, int) {
+	scheduled, played := 0, 0
+	for _, fixture := range s.Fixtures {
+		if fixture.Bye || !((fixture.Home == a && fixture.Away == b) || (fixture.Home == b && fixture.Away == a)) {
+			continue
+		}
+		scheduled++
+		if fixture.Result != "" {
+			played++
+		}
+	}
+	return scheduled, played
+}
+
+// ClubFor finds the club a manager used on a matchday, the latest round which
+// applies wins.
+func (s *Season) ClubFor(manager string, matchday int) string {
+	for i := len(s.ClubRounds) - 1; i >= 0; i-- {
+		round := s.ClubRounds[i]
+		if round.Matchday != 0 && round.Matchday != matchday {
+			continue
+		}
+		for _, assignment := range round.Assignments {
+			if assignment.Manager == manager {
+				return assignment.Club
+			}
+		}
+	}
+	return ""
+}
+func (s *Season) FixtureByID(id string) (bool, int) {
+	for i, fixture := range s.Fixtures {
+		if fixture.ID.Hex() == id {
+			return true, i
+		}
+	}
+	return false, 0
+}
+
+//draft-logic
+func (d *Draft) TotalPicks() int {
+	return len(d.Order) * len(d.Positions)
+}
+
+// OnTheClock returns the manager whose turn it is and the round, false once
+// every pick has been made.
+func (d *Draft) OnTheClock() (string, int, bool) {
+	number := len(d.Picks)
+	if len(d.Order) == 0 || number >= d.TotalPicks() {
+		return "", 0, false
+	}
+	round, slot := number/len(d.Order), number%len(d.Order)
+	if d.Snake && round%2 == 1 {
+		slot = len(d.Order) - 1 - slot
+	}
+	return d.Order[slot], round + 1, true
+}
+
+// OpenSlots lists the positions a manager still has to fill.
+func (d *Draft) OpenSlots(manager string) []string {
+	open := append([]string{}, d.Positions...)
+	for _, pick := range d.Picks {
+		if pick.Manager != manager {
+			continue
+		}
+		for i, position := range open {
+			if position == pick.Position {
+				open = append(open[:i], open[i+1:]...)
+				break
+			}
+		}
+	}
+	return open
+}
+
+// Slot picks the open position a player fills, the first open one when he
+// plays none of them.
+func (d *Draft) Slot(manager string, player Player) string {
+	open := d.OpenSlots(manager)
+	if len(open) == 0 {
+		return ""
+	}
+	for _, position := range open {
+		if player.PlaysPosition(position) {
+			return position
+		}
+	}
+	return open[0]
+}
+func (p Player) PlaysPosition(position string) bool {
+	for _, played := range strings.Split(p.Positions, ",") {
+		if strings.TrimSpace(played) == position {
+			return true
+		}
+	}
+	return false
+}
+
+//bracket-logic
+func (b *Bracket) TieByID(id string) (bool, int, int) {
+	for i, round := range b.Rounds {
+		for j, tie := range round.Ties {
+			if tie.ID.Hex() == id {
+				return true, i, j
+			}
+		}
+	}
+	return false, 0, 0
 }
-func (s *Season) AddResult(r Result) {
-	s.Results = append(s.Results, r)
+func (t *Tie) Decided() bool {
+	return t.Winner != ""
 }
 func (s *Standing) Set(standing Standing) {
+	s.ID = standing.ID
 	s.Manager = standing.Manager
 	s.Points += standing.Points
 	s.Played += standing.Played
@@ -146,5 +723,6 @@ func (s *Standing) Set(standing Standing) {
 	s.GF += standing.GF
 	s.GA += standing.GA
 	s.GD += standing.GD
+	s.FairPlay += standing.FairPlay
 	s.Form = append(s.Form, standing.Form...)
 }